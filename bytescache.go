@@ -0,0 +1,210 @@
+package litecache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// DefaultBytesCacheSize is the default total size of the ring buffers of a bytes cache
+	DefaultBytesCacheSize = 64 << 20
+	// maxBytesShardSize keeps the offsets of a shard in the uint32 of its index
+	maxBytesShardSize uint64 = math.MaxUint32
+)
+
+var (
+	ErrEntryTooLarge = errors.New("entry too large")
+)
+
+// BytesConfig configures a BytesCache
+type BytesConfig struct {
+	hasher   Hasher[string]
+	clock    Clock
+	shards   int
+	maxBytes int
+}
+
+func NewDefaultBytesConfig() BytesConfig {
+	return BytesConfig{
+		hasher:   newDefaultHasher[string](),
+		clock:    systemClock{},
+		shards:   256,
+		maxBytes: DefaultBytesCacheSize,
+	}
+}
+
+func (c BytesConfig) WithHasher(hasher Hasher[string]) BytesConfig {
+	c.hasher = hasher
+	return c
+}
+
+func (c BytesConfig) WithClock(clock Clock) BytesConfig {
+	c.clock = clock
+	return c
+}
+
+func (c BytesConfig) WithShards(shards int) BytesConfig {
+	c.shards = shards
+	return c
+}
+
+// WithMaxBytes sets the total size of the ring buffers, that is split evenly between the shards
+// and allocated upfront. Each entry takes its key, its value and a header of 22 bytes.
+func (c BytesConfig) WithMaxBytes(maxBytes int) BytesConfig {
+	c.maxBytes = maxBytes
+	return c
+}
+
+func (c BytesConfig) validate() error {
+	if c.hasher == nil {
+		return fmt.Errorf("%w: key hash is required", ErrInvalidConfig)
+	}
+
+	if c.clock == nil {
+		return fmt.Errorf("%w: clock is required", ErrInvalidConfig)
+	}
+
+	if c.shards < 1 {
+		return fmt.Errorf("%w: shards should be greater or equal to 1", ErrInvalidConfig)
+	}
+
+	if size := c.maxBytes / c.shards; size <= entryHeaderSize || uint64(size) > maxBytesShardSize {
+		return fmt.Errorf("%w: max bytes per shard should be between %d and %d", ErrInvalidConfig, entryHeaderSize+1, maxBytesShardSize)
+	}
+
+	return nil
+}
+
+// BytesCache stores the keys and the values as bytes in ring buffers, that are allocated upfront,
+// one per shard, and indexes them by the key hash. The buffers and the indexes hold no pointers,
+// so the garbage collector does not scan the entries, no matter how many of them there are.
+// When a buffer is full, the oldest entries of its shard are overwritten, updated and removed entries
+// take space until then. Expired entries are not cleaned by a janitor, they are overwritten the same way.
+type BytesCache struct {
+	cfg    BytesConfig
+	hasher Hasher[string]
+	shards []*bytesShard
+}
+
+func NewBytesCache(cfg BytesConfig) (*BytesCache, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	c := &BytesCache{
+		cfg:    cfg,
+		hasher: cfg.hasher,
+		shards: make([]*bytesShard, cfg.shards),
+	}
+
+	for i := range c.shards {
+		c.shards[i] = newBytesShard(cfg.maxBytes/cfg.shards, cfg.clock)
+	}
+
+	return c, nil
+}
+
+func (c *BytesCache) Set(key string, value []byte) error {
+	return c.set(key, value, NoExpiration)
+}
+
+// SetTtl sets the entry, that expires after ttl, ttl of 0 or less means no expiration
+func (c *BytesCache) SetTtl(key string, value []byte, ttl time.Duration) error {
+	return c.set(key, value, ttl)
+}
+
+func (c *BytesCache) set(key string, value []byte, ttl time.Duration) error {
+	if len(key) > math.MaxUint16 {
+		return fmt.Errorf("%w: key of %d bytes", ErrEntryTooLarge, len(key))
+	}
+
+	var exp int64
+	if ttl > 0 {
+		exp = c.cfg.clock.Now().Add(ttl).UnixNano()
+	}
+
+	hash := c.hasher.Hash(key)
+	if !c.shards[shardIndex(hash, len(c.shards))].set(key, hash, value, exp) {
+		return fmt.Errorf("%w: entry of %d bytes does not fit the shard", ErrEntryTooLarge, entryHeaderSize+len(key)+len(value))
+	}
+
+	return nil
+}
+
+// Get returns a copy of the value, so it stays valid after the entry is overwritten
+func (c *BytesCache) Get(key string) ([]byte, bool) {
+	hash := c.hasher.Hash(key)
+	return c.shards[shardIndex(hash, len(c.shards))].get(key, hash)
+}
+
+func (c *BytesCache) Remove(key string) bool {
+	hash := c.hasher.Hash(key)
+	return c.shards[shardIndex(hash, len(c.shards))].remove(key, hash)
+}
+
+// Count returns the number of indexed entries, including the expired ones, that are not overwritten yet
+func (c *BytesCache) Count() int {
+	count := 0
+	for _, s := range c.shards {
+		count += s.count()
+	}
+	return count
+}
+
+// TypedBytesCache is a BytesCache of values of type V, that are turned into bytes by the codec
+type TypedBytesCache[V any] struct {
+	cache *BytesCache
+	codec Codec[V]
+}
+
+func NewTypedBytesCache[V any](cfg BytesConfig, codec Codec[V]) (*TypedBytesCache[V], error) {
+	if codec == nil {
+		return nil, fmt.Errorf("%w: codec is required", ErrInvalidConfig)
+	}
+
+	cache, err := NewBytesCache(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedBytesCache[V]{cache: cache, codec: codec}, nil
+}
+
+func (c *TypedBytesCache[V]) Set(key string, value V) error {
+	return c.SetTtl(key, value, NoExpiration)
+}
+
+func (c *TypedBytesCache[V]) SetTtl(key string, value V, ttl time.Duration) error {
+	b, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.cache.set(key, b, ttl)
+}
+
+// Get returns the value and whether it was found, or the error of the codec, if the value could not be decoded
+func (c *TypedBytesCache[V]) Get(key string) (V, bool, error) {
+	b, ok := c.cache.Get(key)
+	if !ok {
+		var zero V
+		return zero, false, nil
+	}
+
+	v, err := c.codec.Unmarshal(b)
+	if err != nil {
+		var zero V
+		return zero, false, err
+	}
+
+	return v, true, nil
+}
+
+func (c *TypedBytesCache[V]) Remove(key string) bool {
+	return c.cache.Remove(key)
+}
+
+func (c *TypedBytesCache[V]) Count() int {
+	return c.cache.Count()
+}
@@ -0,0 +1,192 @@
+package litecache_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denismitr/litecache"
+)
+
+func TestBytesCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid config", func(t *testing.T) {
+		for _, cfg := range []litecache.BytesConfig{
+			litecache.NewDefaultBytesConfig().WithShards(0),
+			litecache.NewDefaultBytesConfig().WithHasher(nil),
+			litecache.NewDefaultBytesConfig().WithClock(nil),
+			litecache.NewDefaultBytesConfig().WithShards(16).WithMaxBytes(16 * 10),
+		} {
+			c, err := litecache.NewBytesCache(cfg)
+			require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+			assert.Nil(t, c)
+		}
+	})
+
+	t.Run("set, get and remove", func(t *testing.T) {
+		c, err := litecache.NewBytesCache(litecache.NewDefaultBytesConfig())
+		require.NoError(t, err)
+
+		require.NoError(t, c.Set("foo", []byte("bar")))
+		require.NoError(t, c.Set("empty", nil))
+
+		v, ok := c.Get("foo")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("bar"), v)
+
+		v, ok = c.Get("empty")
+		assert.True(t, ok)
+		assert.Empty(t, v)
+
+		_, ok = c.Get("baz")
+		assert.False(t, ok)
+		assert.Equal(t, 2, c.Count())
+
+		require.NoError(t, c.Set("foo", []byte("updated")))
+		v, _ = c.Get("foo")
+		assert.Equal(t, []byte("updated"), v)
+		assert.Equal(t, 2, c.Count())
+
+		assert.True(t, c.Remove("foo"))
+		assert.False(t, c.Remove("foo"))
+		_, ok = c.Get("foo")
+		assert.False(t, ok)
+		assert.Equal(t, 1, c.Count())
+	})
+
+	t.Run("returned value is a copy", func(t *testing.T) {
+		c, err := litecache.NewBytesCache(litecache.NewDefaultBytesConfig())
+		require.NoError(t, err)
+
+		value := []byte("bar")
+		require.NoError(t, c.Set("foo", value))
+		value[0] = 'c'
+
+		v, _ := c.Get("foo")
+		v[1] = 'x'
+
+		v, _ = c.Get("foo")
+		assert.Equal(t, []byte("bar"), v)
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		clock := litecache.NewFakeClock(time.Now())
+		c, err := litecache.NewBytesCache(litecache.NewDefaultBytesConfig().WithClock(clock))
+		require.NoError(t, err)
+
+		require.NoError(t, c.SetTtl("foo", []byte("bar"), time.Second))
+		require.NoError(t, c.Set("baz", []byte("qux")))
+		require.NoError(t, c.SetTtl("zero", []byte("qux"), 0))
+		require.NoError(t, c.SetTtl("negative", []byte("qux"), -time.Second))
+
+		clock.Advance(500 * time.Millisecond)
+		_, ok := c.Get("foo")
+		assert.True(t, ok)
+
+		clock.Advance(time.Second)
+		_, ok = c.Get("foo")
+		assert.False(t, ok)
+		assert.False(t, c.Remove("foo"))
+
+		// ttl of 0 or less means no expiration, the same as in Cache
+		for _, key := range []string{"baz", "zero", "negative"} {
+			_, ok = c.Get(key)
+			assert.True(t, ok, key)
+		}
+	})
+
+	t.Run("keys with the same hash", func(t *testing.T) {
+		c, err := litecache.NewBytesCache(litecache.NewDefaultBytesConfig().WithHasher(constantHasher{}))
+		require.NoError(t, err)
+
+		require.NoError(t, c.Set("foo", []byte("1")))
+		require.NoError(t, c.Set("bar", []byte("2")))
+
+		// the newer key replaces the older one, which is not mistaken for it
+		_, ok := c.Get("foo")
+		assert.False(t, ok)
+		assert.False(t, c.Remove("foo"))
+
+		v, ok := c.Get("bar")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("2"), v)
+	})
+
+	t.Run("oldest entries are overwritten when the buffer is full", func(t *testing.T) {
+		// a single shard of 1000 bytes fits 20 entries of 50 bytes
+		c, err := litecache.NewBytesCache(litecache.NewDefaultBytesConfig().WithShards(1).WithMaxBytes(1000))
+		require.NoError(t, err)
+
+		value := bytes.Repeat([]byte{'v'}, 50-22-7)
+		for i := 0; i < 50; i++ {
+			require.NoError(t, c.Set(fmt.Sprintf("key:%02d", i), value))
+		}
+
+		assert.Equal(t, 20, c.Count())
+		for i := 0; i < 50; i++ {
+			v, ok := c.Get(fmt.Sprintf("key:%02d", i))
+			if i < 30 {
+				assert.False(t, ok, i)
+				continue
+			}
+
+			assert.True(t, ok, i)
+			assert.Equal(t, value, v)
+		}
+	})
+
+	t.Run("entries wrapping around the end of the buffer", func(t *testing.T) {
+		c, err := litecache.NewBytesCache(litecache.NewDefaultBytesConfig().WithShards(1).WithMaxBytes(1000))
+		require.NoError(t, err)
+
+		// the sizes are not divisors of the buffer size, so the entries straddle its end
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			require.NoError(t, c.Set(key, []byte(strings.Repeat(key, i%7+1))))
+
+			v, ok := c.Get(key)
+			require.True(t, ok, key)
+			assert.Equal(t, strings.Repeat(key, i%7+1), string(v))
+		}
+	})
+
+	t.Run("entries that do not fit", func(t *testing.T) {
+		c, err := litecache.NewBytesCache(litecache.NewDefaultBytesConfig().WithShards(1).WithMaxBytes(100))
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, c.Set("foo", make([]byte, 100)), litecache.ErrEntryTooLarge)
+		assert.ErrorIs(t, c.Set(strings.Repeat("k", 1<<16), nil), litecache.ErrEntryTooLarge)
+		assert.Equal(t, 0, c.Count())
+	})
+
+	t.Run("concurrent access", func(t *testing.T) {
+		c, err := litecache.NewBytesCache(litecache.NewDefaultBytesConfig().WithShards(4).WithMaxBytes(4 * 4096))
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					key := fmt.Sprintf("key:%d", i%200)
+					_ = c.Set(key, []byte(key))
+					if v, ok := c.Get(key); ok {
+						assert.Equal(t, key, string(v))
+					}
+					if i%10 == w {
+						c.Remove(key)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+	})
+}
@@ -2,6 +2,7 @@ package litecache
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -9,62 +10,169 @@ import (
 const (
 	NoExpiration             time.Duration = -1
 	DefaultTtlCheckIntervals               = 300 * time.Millisecond
+
+	// defaultCapacityHint is given to eviction policies of shards, that are bounded only by cost
+	defaultCapacityHint = 1024
 )
 
-type Cache[T any] struct {
-	hasher    hasher
-	shards    []*shard[T]
-	shardMask uint64
-	len       atomic.Int64
+// KeyedCache is a sharded in-memory cache of values of type V by keys of type K
+type KeyedCache[K comparable, V any] struct {
+	cfg        KeyedConfig[K, V]
+	hasher     Hasher[K]
+	layout     atomic.Pointer[layout[K, V]]
+	reshardMux sync.Mutex
+	onEvict    func(key K, value V)
+	len        atomic.Int64
+	ctx        context.Context
+	clock      Clock
+	loads      flightGroup[K, V]
+	loading    loaderConfig[K, V]
+}
+
+// Cache is a sharded in-memory cache of values of type V by string keys,
+// it has all the methods of KeyedCache
+type Cache[V any] struct {
+	*KeyedCache[string, V]
 }
 
 // New - creates a new cache
-func New[T any](ctx context.Context) *Cache[T] {
-	cfg := NewDefaultConfig[T]()
-	return newWithConfig[T](ctx, cfg)
+func New[V any](ctx context.Context) *Cache[V] {
+	cfg := NewDefaultConfig[V]()
+	return &Cache[V]{KeyedCache: newWithConfig[string, V](ctx, cfg.keyed)}
 }
 
-func NewWithConfig[T any](ctx context.Context, cfg Config[T]) (*Cache[T], error) {
+func NewWithConfig[V any](ctx context.Context, cfg Config[V]) (*Cache[V], error) {
+	c, err := NewKeyed[string, V](ctx, cfg.keyed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache[V]{KeyedCache: c}, nil
+}
+
+// NewKeyed creates a cache with keys of type K, the config should come from NewKeyedConfig
+func NewKeyed[K comparable, V any](ctx context.Context, cfg KeyedConfig[K, V]) (*KeyedCache[K, V], error) {
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
 
-	return newWithConfig[T](ctx, cfg), nil
+	return newWithConfig[K, V](ctx, cfg), nil
 }
 
-func newWithConfig[T any](ctx context.Context, cfg Config[T]) *Cache[T] {
-	c := &Cache[T]{
-		shardMask: uint64(cfg.shards - 1),
-		shards:    make([]*shard[T], cfg.shards),
-		hasher:    newDefaultHasher(),
+func newWithConfig[K comparable, V any](ctx context.Context, cfg KeyedConfig[K, V]) *KeyedCache[K, V] {
+	c := &KeyedCache[K, V]{
+		cfg:     cfg,
+		hasher:  cfg.hasher,
+		ctx:     ctx,
+		loading: cfg.loading,
 	}
 
-	j := newJanitor[T](ctx, cfg.ttlChecksInterval)
-	for i := range c.shards {
-		c.shards[i] = newShard[T]()
-		j.runOn(c.shards[i], func(key string, value T) {
-			c.len.Add(-1)
-			if cfg.onEvict != nil {
-				cfg.onEvict(key, value)
-			}
-		})
+	c.onEvict = func(key K, value V) {
+		c.len.Add(-1)
+		if cfg.onEvict != nil {
+			cfg.onEvict(key, value)
+		}
+	}
+
+	clock := cfg.clock
+	if cfg.clockResolution > 0 {
+		clock = newCoarseClock(ctx, clock, cfg.clockResolution)
+	}
+	c.clock = clock
+
+	c.layout.Store(&layout[K, V]{shards: c.newShards(cfg.shards)})
+
+	newJanitor[K, V](ctx, clock, cfg.ttlChecksInterval, cfg.janitorBudget, cfg.janitorTimeBudget, c.currentShards).run()
+
+	if cfg.memoryWatchdog {
+		newWatchdog[K, V](ctx, clock, cfg.memoryCheckInterval, cfg.memorySoftLimit, c.allShards).run()
 	}
 
 	return c
 }
 
-func (c *Cache[T]) getShard(key string) *shard[T] {
+// newShards creates n shards, max entries and max cost are split between the shards,
+// the first shards get one more of the remainder, so the shares add up to the bounds
+func (c *KeyedCache[K, V]) newShards(n int) []*shard[K, V] {
+	shards := make([]*shard[K, V], n)
+	for i := range shards {
+		var capacity int
+		if c.cfg.maxEntries > 0 {
+			capacity = shardShare(c.cfg.maxEntries, n, i)
+		}
+
+		var maxCost int64
+		if c.cfg.maxCost > 0 {
+			maxCost = shardShare(c.cfg.maxCost, int64(n), int64(i))
+		}
+
+		sc := shardConfig[K, V]{
+			capacity: capacity,
+			maxCost:  maxCost,
+			costFunc: c.cfg.costFunc,
+			onEvict:  c.onEvict,
+			clock:    c.clock,
+			lockFree: c.cfg.lockFreeReads,
+		}
+
+		if capacity > 0 || maxCost > 0 {
+			// policies of shards bounded only by cost get a capacity hint instead
+			hint := capacity
+			if hint == 0 {
+				hint = defaultCapacityHint
+			}
+
+			sc.policy = c.cfg.policyFactory()(hint)
+			if c.cfg.tinyLFU {
+				sc.admission = newTinyLFU(hint, c.hasher)
+			}
+		}
+
+		shards[i] = newShard[K, V](sc)
+	}
+
+	return shards
+}
+
+func (c *KeyedCache[K, V]) getShard(key K) *shard[K, V] {
+	l := c.layout.Load()
+	if l.old != nil {
+		return c.evacuate(l, key)
+	}
+
 	hk := c.hasher.Hash(key)
-	return c.shards[hk&c.shardMask]
+	return l.shards[shardIndex(hk, len(l.shards))]
+}
+
+// shardShare returns the share of the total for the i-th of n shards
+func shardShare[T int | int64](total, n, i T) T {
+	share := total / n
+	if i < total%n {
+		share++
+	}
+	return share
+}
+
+// shardIndex maps the key hash to one of n shards, power of two shard counts take the low bits of the hash,
+// other counts fall back to modulo, masking them would leave the shards, which index has a bit unset in the mask, empty
+func shardIndex(hk uint64, n int) int {
+	if n&(n-1) == 0 {
+		return int(hk & uint64(n-1))
+	}
+	return int(hk % uint64(n))
 }
 
 // Get return value for a key, if it exists and has not expired
 // zero value is returned if the key was not found or has expired
-func (c *Cache[T]) Get(key string) (T, bool) {
+func (c *KeyedCache[K, V]) Get(key K) (V, bool) {
 	shard := c.getShard(key)
 	item, found := shard.get(key)
 	if !found {
-		return zeroV[T](), false
+		return zeroV[V](), false
+	}
+
+	if item.refresh > 0 {
+		c.refreshIfDue(key, item.refresh)
 	}
 
 	return item.value, true
@@ -72,64 +180,96 @@ func (c *Cache[T]) Get(key string) (T, bool) {
 
 // Transform can change the value of the given key atomically
 // it does not modify the ttl of the key
-func (c *Cache[T]) Transform(key string, effector func(value T) T) bool {
+func (c *KeyedCache[K, V]) Transform(key K, effector func(value V) V) bool {
 	shard := c.getShard(key)
 	return shard.transform(key, effector)
 }
 
 // ForEach iterates over all the keys and values that are not expired in the cache
-// the method uses mutex to lock the content of the cache for reading
-func (c *Cache[T]) ForEach(fn func(k string, v T)) {
-	for _, s := range c.shards {
+// the method uses mutex to lock the content of the cache for reading,
+// during resharding an entry, that moves while being iterated, may be visited twice
+func (c *KeyedCache[K, V]) ForEach(fn func(k K, v V)) {
+	for _, s := range c.allShards() {
 		s.iterate(fn)
 	}
 }
 
 // Set - sets key value pair.
 // it will update the value if key already exists in the cache and has not expired.
-func (c *Cache[T]) Set(key string, value T) {
+func (c *KeyedCache[K, V]) Set(key K, value V) {
 	shard := c.getShard(key)
-	if shard.set(key, value, NoExpiration) {
+	if shard.set(key, value, NoExpiration, autoCost) {
+		c.len.Add(1)
+	}
+}
+
+// SetWithCost - sets key value pair with the given cost, instead of the one computed by the cost func.
+// it will update the value if key already exists in the cache and has not expired.
+// the cost matters only when the cache is bounded by max cost, negative costs count as 0.
+func (c *KeyedCache[K, V]) SetWithCost(key K, value V, cost int64) {
+	shard := c.getShard(key)
+	if shard.set(key, value, NoExpiration, max(cost, 0)) {
 		c.len.Add(1)
 	}
 }
 
 // SetTtl - sets key value pair with ttl.
 // it will update the value if key already exists in the cache and has not expired.
-func (c *Cache[T]) SetTtl(key string, value T, ttl time.Duration) {
+func (c *KeyedCache[K, V]) SetTtl(key K, value V, ttl time.Duration) {
+	shard := c.getShard(key)
+	if shard.set(key, value, ttl, autoCost) {
+		c.len.Add(1)
+	}
+}
+
+// SetSliding - sets key value pair with sliding expiration.
+// every successful Get pushes the expiration forward by idle, so the key lives as long as it is being read.
+// it will update the value if key already exists in the cache and has not expired.
+func (c *KeyedCache[K, V]) SetSliding(key K, value V, idle time.Duration) {
+	shard := c.getShard(key)
+	if shard.setSliding(key, value, idle) {
+		c.len.Add(1)
+	}
+}
+
+// SetTtlWithCost - sets key value pair with ttl and the given cost, instead of the one computed by the cost func.
+// it will update the value if key already exists in the cache and has not expired.
+// the cost matters only when the cache is bounded by max cost, negative costs count as 0.
+func (c *KeyedCache[K, V]) SetTtlWithCost(key K, value V, ttl time.Duration, cost int64) {
 	shard := c.getShard(key)
-	if shard.set(key, value, ttl) {
+	if shard.set(key, value, ttl, max(cost, 0)) {
 		c.len.Add(1)
 	}
 }
 
 // SetNx - sets key value pair only if key does not exist in the cache or has expired.
 // if the key value pair was set successfully it returns true
-func (c *Cache[T]) SetNx(key string, value T) bool {
+func (c *KeyedCache[K, V]) SetNx(key K, value V) bool {
 	shard := c.getShard(key)
-	if shard.setNX(key, value, NoExpiration) {
+	stored, added := shard.setNX(key, value, NoExpiration)
+	if added {
 		c.len.Add(1)
-		return true
 	}
 
-	return false
+	return stored
 }
 
 // SetNxTtl - sets key value only if key does not exist in the cache or has expired.
 // ttl expected to be given as a last parameter.
 // if the key value pair was set successfully it returns true
-func (c *Cache[T]) SetNxTtl(key string, value T, ttl time.Duration) bool {
+func (c *KeyedCache[K, V]) SetNxTtl(key K, value V, ttl time.Duration) bool {
 	shard := c.getShard(key)
-	if shard.setNX(key, value, ttl) {
+	stored, added := shard.setNX(key, value, ttl)
+	if added {
 		c.len.Add(1)
-		return true
 	}
-	return false
+
+	return stored
 }
 
 // SetEx - updates key value pair if key already exists and not expired in the cache.
 // if value was updated, returns true
-func (c *Cache[T]) SetEx(key string, value T) bool {
+func (c *KeyedCache[K, V]) SetEx(key K, value V) bool {
 	shard := c.getShard(key)
 	return shard.setEX(key, value, NoExpiration)
 }
@@ -137,7 +277,7 @@ func (c *Cache[T]) SetEx(key string, value T) bool {
 // SetExTtl - updates key value pair if key already exists and not expired in the cache.
 // ttl expiration is expected to be given as a last parameter.
 // if value was updated, returns true
-func (c *Cache[T]) SetExTtl(key string, value T, ttl time.Duration) bool {
+func (c *KeyedCache[K, V]) SetExTtl(key K, value V, ttl time.Duration) bool {
 	shard := c.getShard(key)
 	if shard.setEX(key, value, ttl) {
 		c.len.Add(1)
@@ -149,21 +289,21 @@ func (c *Cache[T]) SetExTtl(key string, value T, ttl time.Duration) bool {
 // GetAndSetExTtl sets the value for existing key, only if it exists in the cache
 // it will return the old value and true if the key found in cache and zero value and false if not found or expired
 // ttl expiration is expected to be given as a last parameter.
-func (c *Cache[T]) GetAndSetExTtl(key string, value T, ttl time.Duration) (T, bool) {
+func (c *KeyedCache[K, V]) GetAndSetExTtl(key K, value V, ttl time.Duration) (V, bool) {
 	shard := c.getShard(key)
 	return shard.getSetEX(key, value, ttl)
 }
 
 // GetAndSetEx sets the value for existing key, only if it exists in the cache
 // it will return the old value and true if the key found in cache and zero value and false if not found or expired
-func (c *Cache[T]) GetAndSetEx(key string, value T) (T, bool) {
+func (c *KeyedCache[K, V]) GetAndSetEx(key K, value V) (V, bool) {
 	shard := c.getShard(key)
 	return shard.getSetEX(key, value, NoExpiration)
 }
 
 // Remove - removes the value from cache if present
 // returns true if key was found and false if it was not
-func (c *Cache[T]) Remove(key string) bool {
+func (c *KeyedCache[K, V]) Remove(key K) bool {
 	s := c.getShard(key)
 	_, found := s.remove(key)
 	if found {
@@ -174,7 +314,7 @@ func (c *Cache[T]) Remove(key string) bool {
 
 // GetAndRemove - removes the value from cache if present
 // returns value and boolean true if key was found and zero value and boolean false if it was not
-func (c *Cache[T]) GetAndRemove(key string) (T, bool) {
+func (c *KeyedCache[K, V]) GetAndRemove(key K) (V, bool) {
 	s := c.getShard(key)
 	v, found := s.remove(key)
 	if found {
@@ -185,19 +325,49 @@ func (c *Cache[T]) GetAndRemove(key string) (T, bool) {
 
 // Count returns the number of in the cache keys.
 // It might get delayed updates when keys expire.
-func (c *Cache[T]) Count() int {
+func (c *KeyedCache[K, V]) Count() int {
 	return int(c.len.Load())
 }
 
-func (c *Cache[T]) CountPrecise() int {
+// Cost returns the total cost of the keys in the cache,
+// it is always 0 when the cache is not bounded by max cost.
+func (c *KeyedCache[K, V]) Cost() int64 {
+	var total int64
+	for _, s := range c.allShards() {
+		total += s.totalCost()
+	}
+	return total
+}
+
+// ShardStats describes the content of a single shard
+type ShardStats struct {
+	// Entries is the number of entries in the shard, including the expired ones, that are not cleaned yet
+	Entries int
+	// Cost is the total cost of the entries, it is always 0 when the cache is not bounded by max cost
+	Cost int64
+}
+
+// ShardStats returns the stats of every shard in the order of the shards,
+// it shows how evenly the keys are spread over the shards. During resharding
+// only the new shards are described, while the entries are still moving to them.
+func (c *KeyedCache[K, V]) ShardStats() []ShardStats {
+	shards := c.currentShards()
+	stats := make([]ShardStats, len(shards))
+	for i, s := range shards {
+		stats[i] = s.stats()
+	}
+	return stats
+}
+
+func (c *KeyedCache[K, V]) CountPrecise() int {
 	var total int
-	for _, s := range c.shards {
+	for _, s := range c.allShards() {
 		total += s.countPrecise()
 	}
 	return total
 }
 
-func zeroV[T any]() T {
-	var v T
+func zeroV[V any]() V {
+	var v V
 	return v
 }
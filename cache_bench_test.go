@@ -0,0 +1,135 @@
+package litecache_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/denismitr/litecache"
+)
+
+func BenchmarkCache_Get(b *testing.B) {
+	const N = 100_000
+
+	keys := make([]string, N)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key:%d", i)
+	}
+
+	for _, bc := range []struct {
+		name string
+		cfg  litecache.Config[int]
+	}{
+		{name: "system clock", cfg: litecache.NewDefaultConfig[int]()},
+		{name: "coarse clock", cfg: litecache.NewDefaultConfig[int]().WithCoarseClock(time.Millisecond)},
+		{name: "lock free reads", cfg: litecache.NewDefaultConfig[int]().WithLockFreeReads(true)},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c, err := litecache.NewWithConfig[int](ctx, bc.cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			for i, k := range keys {
+				c.SetTtl(k, i, time.Hour)
+			}
+
+			// lets the lock free shards index the keys before measuring
+			for _, k := range keys {
+				c.Get(k)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.Get(keys[i%N])
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkKeyedCache_Get(b *testing.B) {
+	const N = 100_000
+
+	b.Run("formatted string keys", func(b *testing.B) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := litecache.New[int](ctx)
+		for i := 0; i < N; i++ {
+			c.SetTtl(fmt.Sprintf("user:%d", i), i, time.Hour)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				c.Get(fmt.Sprintf("user:%d", i%N))
+				i++
+			}
+		})
+	})
+
+	b.Run("int64 keys", func(b *testing.B) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c, err := litecache.NewKeyed[int64, int](ctx, litecache.NewKeyedConfig[int64, int]())
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for i := 0; i < N; i++ {
+			c.SetTtl(int64(i), i, time.Hour)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				c.Get(int64(i % N))
+				i++
+			}
+		})
+	})
+}
+
+func BenchmarkBytesCache_Get(b *testing.B) {
+	const N = 100_000
+
+	keys := make([]string, N)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key:%d", i)
+	}
+
+	c, err := litecache.NewBytesCache(litecache.NewDefaultBytesConfig())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, k := range keys {
+		if err := c.SetTtl(k, []byte(k), time.Hour); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(keys[i%N])
+			i++
+		}
+	})
+}
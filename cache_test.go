@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/stretchr/testify/require"
+	"hash/fnv"
+	"reflect"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
@@ -738,3 +741,275 @@ func TestCache_Transform(t *testing.T) {
 		assert.Equal(t, 0, v2)
 	})
 }
+
+func TestCache_SetSliding(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reads keep the key alive", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := litecache.New[int](ctx)
+		c.SetSliding("foo", 3, 100*time.Millisecond)
+		assert.Equal(t, 1, c.Count())
+
+		for i := 0; i < 5; i++ {
+			time.Sleep(50 * time.Millisecond)
+			v, found := c.Get("foo")
+			assert.True(t, found)
+			assert.Equal(t, 3, v)
+		}
+
+		time.Sleep(150 * time.Millisecond)
+
+		{
+			v, found := c.Get("foo")
+			assert.False(t, found)
+			assert.Equal(t, 0, v)
+		}
+	})
+
+	t.Run("transform keeps sliding expiration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().WithShards(1).WithMaxEntries(10).WithEvictionMode(litecache.EvictSIEVE)
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.SetSliding("foo", 3, 100*time.Millisecond)
+		assert.True(t, c.Transform("foo", func(n int) int { return n * 2 }))
+
+		for i := 0; i < 3; i++ {
+			time.Sleep(50 * time.Millisecond)
+			v, found := c.Get("foo")
+			assert.True(t, found)
+			assert.Equal(t, 6, v)
+		}
+
+		time.Sleep(150 * time.Millisecond)
+		_, found := c.Get("foo")
+		assert.False(t, found)
+	})
+
+	t.Run("set replaces sliding expiration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := litecache.New[int](ctx)
+		c.SetSliding("foo", 3, 50*time.Millisecond)
+		c.SetTtl("foo", 4, 100*time.Millisecond)
+		assert.Equal(t, 1, c.Count())
+
+		time.Sleep(60 * time.Millisecond)
+		{
+			v, found := c.Get("foo")
+			assert.True(t, found)
+			assert.Equal(t, 4, v)
+		}
+
+		time.Sleep(60 * time.Millisecond)
+		{
+			_, found := c.Get("foo")
+			assert.False(t, found)
+		}
+	})
+}
+
+type userID int64
+
+type tenantKey struct {
+	tenant string
+	id     int
+}
+
+func TestKeyedCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("string keyed cache keeps a single type parameter", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var cfg litecache.Config[int] = litecache.NewDefaultConfig[int]().WithShards(4).WithOnEvict(func(key string, value int) {})
+		var c *litecache.Cache[int]
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("foo", 1)
+		var embedded *litecache.KeyedCache[string, int] = c.KeyedCache
+		v, found := embedded.Get("foo")
+		assert.True(t, found)
+		assert.Equal(t, 1, v)
+
+		// every option of the keyed config is available to the string keyed one
+		keyed, stringKeyed := reflect.TypeOf(litecache.KeyedConfig[string, int]{}), reflect.TypeOf(litecache.Config[int]{})
+		for i := 0; i < keyed.NumMethod(); i++ {
+			name := keyed.Method(i).Name
+			m, ok := stringKeyed.MethodByName(name)
+			if assert.True(t, ok, name) {
+				assert.Equal(t, stringKeyed, m.Type.Out(0), name)
+			}
+		}
+	})
+
+	t.Run("integer keys", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c, err := litecache.NewKeyed[int64, string](ctx, litecache.NewKeyedConfig[int64, string]())
+		require.NoError(t, err)
+
+		for i := int64(0); i < 1000; i++ {
+			c.Set(i, fmt.Sprintf("value %d", i))
+		}
+		assert.Equal(t, 1000, c.Count())
+
+		v, found := c.Get(42)
+		assert.True(t, found)
+		assert.Equal(t, "value 42", v)
+
+		assert.True(t, c.Remove(42))
+		_, found = c.Get(42)
+		assert.False(t, found)
+		assert.Equal(t, 999, c.Count())
+	})
+
+	t.Run("named integer keys with eviction and loader", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var evicted []userID
+		cfg := litecache.NewKeyedConfig[userID, string]().
+			WithShards(1).
+			WithMaxEntries(2).
+			WithOnEvict(func(key userID, value string) {
+				evicted = append(evicted, key)
+			}).
+			WithLoader(func(ctx context.Context, key userID) (string, error) {
+				return fmt.Sprintf("user %d", key), nil
+			})
+
+		c, err := litecache.NewKeyed[userID, string](ctx, cfg)
+		require.NoError(t, err)
+
+		for _, id := range []userID{1, 2, 1, 3} {
+			v, err := c.Fetch(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("user %d", id), v)
+		}
+
+		assert.Equal(t, []userID{2}, evicted)
+		assert.Equal(t, 2, c.Count())
+	})
+
+	t.Run("struct keys need a hash func", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		_, err := litecache.NewKeyed[tenantKey, int](ctx, litecache.NewKeyedConfig[tenantKey, int]())
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+
+		cfg := litecache.NewKeyedConfig[tenantKey, int]().
+			WithKeyHash(func(key tenantKey) uint64 {
+				h := fnv.New64a()
+				h.Write([]byte(key.tenant))
+				return h.Sum64() ^ uint64(key.id)
+			})
+
+		c, err := litecache.NewKeyed[tenantKey, int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set(tenantKey{tenant: "acme", id: 1}, 10)
+		c.Set(tenantKey{tenant: "umbrella", id: 1}, 20)
+
+		v, found := c.Get(tenantKey{tenant: "acme", id: 1})
+		assert.True(t, found)
+		assert.Equal(t, 10, v)
+
+		v, found = c.Get(tenantKey{tenant: "umbrella", id: 1})
+		assert.True(t, found)
+		assert.Equal(t, 20, v)
+
+		_, found = c.Get(tenantKey{tenant: "acme", id: 2})
+		assert.False(t, found)
+	})
+}
+
+func TestCache_ShardStats(t *testing.T) {
+	t.Parallel()
+
+	const entries = 100_000
+
+	for _, shards := range []int{1, 7, 20, 50, 64} {
+		shards := shards
+		t.Run(fmt.Sprintf("%d shards", shards), func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithShards(shards))
+			require.NoError(t, err)
+
+			ids, err := litecache.NewKeyed[int64, int](ctx, litecache.NewKeyedConfig[int64, int]().WithShards(shards))
+			require.NoError(t, err)
+
+			for i := 0; i < entries; i++ {
+				c.Set(fmt.Sprintf("key:%d", i), i)
+				ids.Set(int64(i), i)
+			}
+
+			for _, stats := range [][]litecache.ShardStats{c.ShardStats(), ids.ShardStats()} {
+				require.Len(t, stats, shards)
+
+				mean := float64(entries) / float64(shards)
+				total := 0
+				for i, s := range stats {
+					total += s.Entries
+					assert.InDelta(t, mean, s.Entries, mean*0.1, "shard %d", i)
+				}
+				assert.Equal(t, entries, total)
+			}
+		})
+	}
+}
+
+func TestConfig_WithAutoShards(t *testing.T) {
+	t.Parallel()
+
+	procs := runtime.GOMAXPROCS(0)
+	isPowerOfTwo := func(n int) bool { return n > 0 && n&(n-1) == 0 }
+
+	shardsOf := func(t *testing.T, expectedEntries int) int {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithAutoShards(expectedEntries))
+		require.NoError(t, err)
+		return len(c.ShardStats())
+	}
+
+	t.Run("unknown size", func(t *testing.T) {
+		shards := shardsOf(t, 0)
+		assert.True(t, isPowerOfTwo(shards), shards)
+		assert.GreaterOrEqual(t, shards, procs*4)
+		assert.Less(t, shards, procs*8)
+	})
+
+	t.Run("small cache", func(t *testing.T) {
+		assert.Equal(t, 1, shardsOf(t, 100))
+
+		shards := shardsOf(t, 4096)
+		assert.True(t, isPowerOfTwo(shards), shards)
+		assert.LessOrEqual(t, shards, 16)
+		assert.LessOrEqual(t, shards, shardsOf(t, 0))
+	})
+
+	t.Run("large cache", func(t *testing.T) {
+		const expected = 1 << 26
+		shards := shardsOf(t, expected)
+		assert.True(t, isPowerOfTwo(shards), shards)
+		assert.GreaterOrEqual(t, shards, shardsOf(t, 0))
+		assert.LessOrEqual(t, expected/shards, 1<<16)
+	})
+}
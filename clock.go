@@ -0,0 +1,154 @@
+package litecache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the source of time for expiration checks and background jobs of the cache
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks of a Clock
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock is a Clock, that moves only when it is advanced, it is meant for tests.
+// Tickers created by the fake clock fire when the clock is advanced past their next tick,
+// like the tickers of the time package they drop ticks, that the receiver does not keep up with.
+type FakeClock struct {
+	mux     sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock creates a fake clock, that is set to the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t := &fakeTicker{
+		clock:  c,
+		c:      make(chan time.Time, 1),
+		period: d,
+		next:   c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward and fires the tickers that are due
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		if t.next.After(c.now) {
+			continue
+		}
+
+		select {
+		case t.c <- c.now:
+		default:
+		}
+
+		for !t.next.After(c.now) {
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+func (c *FakeClock) stop(t *fakeTicker) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for i, ticker := range c.tickers {
+		if ticker == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.stop(t)
+}
+
+// coarseClock publishes the time of the underlying clock once per resolution, so reading the time
+// is a single atomic load. Expirations are checked up to the resolution late.
+type coarseClock struct {
+	Clock
+	now atomic.Int64
+}
+
+// newCoarseClock creates a coarse clock, that is updated until the context is done
+func newCoarseClock(ctx context.Context, clock Clock, resolution time.Duration) *coarseClock {
+	c := &coarseClock{Clock: clock}
+	c.now.Store(clock.Now().UnixNano())
+
+	tick := clock.NewTicker(resolution)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				tick.Stop()
+				return
+			case <-tick.C():
+				c.now.Store(clock.Now().UnixNano())
+			}
+		}
+	}()
+
+	return c
+}
+
+func (c *coarseClock) Now() time.Time {
+	return time.Unix(0, c.now.Load())
+}
@@ -0,0 +1,181 @@
+package litecache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denismitr/litecache"
+)
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ticker fires when advanced", func(t *testing.T) {
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock := litecache.NewFakeClock(start)
+		ticker := clock.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		clock.Advance(500 * time.Millisecond)
+		select {
+		case <-ticker.C():
+			t.Fatal("ticker should not fire before the period")
+		default:
+		}
+
+		clock.Advance(3 * time.Second)
+		select {
+		case tick := <-ticker.C():
+			assert.Equal(t, start.Add(3500*time.Millisecond), tick)
+		default:
+			t.Fatal("ticker should fire after the period")
+		}
+
+		// ticks that were not received are dropped
+		select {
+		case <-ticker.C():
+			t.Fatal("ticker should drop missed ticks")
+		default:
+		}
+
+		assert.Equal(t, start.Add(3500*time.Millisecond), clock.Now())
+	})
+
+	t.Run("stopped ticker does not fire", func(t *testing.T) {
+		clock := litecache.NewFakeClock(time.Now())
+		ticker := clock.NewTicker(time.Second)
+		ticker.Stop()
+
+		clock.Advance(time.Minute)
+		select {
+		case <-ticker.C():
+			t.Fatal("stopped ticker should not fire")
+		default:
+		}
+	})
+}
+
+func TestCache_WithFakeClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil clock", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithClock(nil))
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+		require.Nil(t, c)
+	})
+
+	t.Run("keys expire when the clock is advanced", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		evicted := make(chan string, 10)
+		cfg := litecache.NewDefaultConfig[int]().
+			WithClock(clock).
+			WithOnEvict(func(key string, value int) {
+				evicted <- key
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.SetTtl("foo", 1, time.Hour)
+		c.Set("bar", 2)
+
+		clock.Advance(59 * time.Minute)
+		{
+			v, found := c.Get("foo")
+			assert.True(t, found)
+			assert.Equal(t, 1, v)
+		}
+
+		clock.Advance(time.Minute + time.Nanosecond)
+		{
+			_, found := c.Get("foo")
+			assert.False(t, found)
+			assert.False(t, c.Transform("foo", func(v int) int { return v }))
+			assert.True(t, c.SetNx("foo", 3))
+		}
+
+		c.SetTtl("baz", 4, time.Minute)
+		clock.Advance(time.Minute + litecache.DefaultTtlCheckIntervals)
+
+		// foo might have been evicted by the janitor, before it was set again
+		for key := ""; key != "baz"; {
+			select {
+			case key = <-evicted:
+			case <-time.After(time.Second):
+				t.Fatal("janitor should evict the expired key")
+			}
+		}
+
+		assert.Equal(t, 2, c.Count())
+		assert.Equal(t, 2, c.CountPrecise())
+	})
+
+	t.Run("sliding expiration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		c, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithClock(clock))
+		require.NoError(t, err)
+
+		c.SetSliding("session", 1, 30*time.Minute)
+		for i := 0; i < 10; i++ {
+			clock.Advance(20 * time.Minute)
+			_, found := c.Get("session")
+			require.True(t, found)
+		}
+
+		clock.Advance(31 * time.Minute)
+		_, found := c.Get("session")
+		assert.False(t, found)
+	})
+}
+
+func TestCache_WithCoarseClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("negative resolution", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithCoarseClock(-1))
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+		require.Nil(t, c)
+	})
+
+	t.Run("time moves once per resolution", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		cfg := litecache.NewDefaultConfig[int]().
+			WithClock(clock).
+			WithCoarseClock(time.Second)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.SetTtl("foo", 1, 100*time.Millisecond)
+
+		// the coarse clock has not seen the advance yet
+		clock.Advance(500 * time.Millisecond)
+		_, found := c.Get("foo")
+		assert.True(t, found)
+
+		clock.Advance(500 * time.Millisecond)
+		require.Eventually(t, func() bool {
+			_, found := c.Get("foo")
+			return !found
+		}, time.Second, time.Millisecond)
+	})
+}
@@ -0,0 +1,115 @@
+package litecache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// Codec turns values into bytes and back, so that they can leave the process or the Go heap,
+// it is called concurrently, so implementations must be safe for concurrent use.
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(b []byte) (T, error)
+}
+
+// GobCodec encodes values with encoding/gob, every value carries its type description,
+// so it suits values of complex types better than small ones
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Unmarshal(b []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v)
+	return v, err
+}
+
+// JSONCodec encodes values with encoding/json, only the exported fields of structs are kept
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Unmarshal(b []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
+// RawCodec passes byte slices through as they are, without copying them
+type RawCodec struct{}
+
+func (RawCodec) Marshal(v []byte) ([]byte, error) {
+	return v, nil
+}
+
+func (RawCodec) Unmarshal(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+// newDefaultKeyCodec returns the built-in codec for the keys of string and integer kinds,
+// including the named types like `type UserID int64`, and nil for other key types
+func newDefaultKeyCodec[K comparable]() Codec[K] {
+	switch keyKind[K]() {
+	case reflect.String:
+		return stringKeyCodec[K]{}
+	case reflect.Int:
+		return intKeyCodec[K]{}
+	default:
+		return nil
+	}
+}
+
+// stringKeyCodec encodes keys of string kind as their bytes
+type stringKeyCodec[K comparable] struct{}
+
+func (stringKeyCodec[K]) Marshal(key K) ([]byte, error) {
+	// K is of string kind, so it has the memory layout of a string
+	return []byte(*(*string)(unsafe.Pointer(&key))), nil
+}
+
+func (stringKeyCodec[K]) Unmarshal(b []byte) (K, error) {
+	var key K
+	*(*string)(unsafe.Pointer(&key)) = string(b)
+	return key, nil
+}
+
+// intKeyCodec encodes keys of integer kinds as 8 little endian bytes
+type intKeyCodec[K comparable] struct{}
+
+func (intKeyCodec[K]) Marshal(key K) ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(nil, intBits(key)), nil
+}
+
+func (intKeyCodec[K]) Unmarshal(b []byte) (K, error) {
+	var key K
+	if len(b) != 8 {
+		return key, fmt.Errorf("integer key of %d bytes", len(b))
+	}
+
+	bits := binary.LittleEndian.Uint64(b)
+	p := unsafe.Pointer(&key)
+	switch unsafe.Sizeof(key) {
+	case 1:
+		*(*uint8)(p) = uint8(bits)
+	case 2:
+		*(*uint16)(p) = uint16(bits)
+	case 4:
+		*(*uint32)(p) = uint32(bits)
+	default:
+		*(*uint64)(p) = bits
+	}
+	return key, nil
+}
@@ -0,0 +1,109 @@
+package litecache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denismitr/litecache"
+)
+
+type profile struct {
+	Name  string
+	Age   int
+	Tags  []string
+	Since time.Time
+}
+
+func TestCodecs(t *testing.T) {
+	t.Parallel()
+
+	p := profile{Name: "Joe", Age: 42, Tags: []string{"a", "b"}, Since: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	for _, tc := range []struct {
+		name  string
+		codec litecache.Codec[profile]
+	}{
+		{name: "gob", codec: litecache.GobCodec[profile]{}},
+		{name: "json", codec: litecache.JSONCodec[profile]{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.codec.Marshal(p)
+			require.NoError(t, err)
+
+			decoded, err := tc.codec.Unmarshal(b)
+			require.NoError(t, err)
+			assert.Equal(t, p, decoded)
+
+			_, err = tc.codec.Unmarshal([]byte("garbage"))
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("raw", func(t *testing.T) {
+		var codec litecache.Codec[[]byte] = litecache.RawCodec{}
+
+		b, err := codec.Marshal([]byte("foo"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("foo"), b)
+
+		v, err := codec.Unmarshal(b)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("foo"), v)
+	})
+}
+
+func TestTypedBytesCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("codec is required", func(t *testing.T) {
+		c, err := litecache.NewTypedBytesCache[profile](litecache.NewDefaultBytesConfig(), nil)
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+		assert.Nil(t, c)
+	})
+
+	t.Run("set, get and remove", func(t *testing.T) {
+		c, err := litecache.NewTypedBytesCache(litecache.NewDefaultBytesConfig(), litecache.GobCodec[profile]{})
+		require.NoError(t, err)
+
+		p := profile{Name: "Joe", Age: 42}
+		require.NoError(t, c.Set("joe", p))
+
+		v, ok, err := c.Get("joe")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, p, v)
+
+		_, ok, err = c.Get("ann")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 1, c.Count())
+
+		assert.True(t, c.Remove("joe"))
+		_, ok, _ = c.Get("joe")
+		assert.False(t, ok)
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		clock := litecache.NewFakeClock(time.Now())
+		c, err := litecache.NewTypedBytesCache(litecache.NewDefaultBytesConfig().WithClock(clock), litecache.JSONCodec[int]{})
+		require.NoError(t, err)
+
+		require.NoError(t, c.SetTtl("foo", 1, time.Second))
+		clock.Advance(2 * time.Second)
+
+		_, ok, err := c.Get("foo")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("marshal error", func(t *testing.T) {
+		c, err := litecache.NewTypedBytesCache(litecache.NewDefaultBytesConfig(), litecache.JSONCodec[func()]{})
+		require.NoError(t, err)
+
+		assert.Error(t, c.Set("foo", func() {}))
+		assert.Equal(t, 0, c.Count())
+	})
+}
@@ -1,47 +1,480 @@
 package litecache
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"runtime"
 	"time"
 )
 
+const (
+	// autoShardsPerProc is the number of shards per processor, that keeps the lock contention low
+	autoShardsPerProc = 4
+	// autoShardsMinEntries is the number of entries per shard, below which more shards do not pay off
+	autoShardsMinEntries = 256
+	// autoShardsMaxEntries is the number of entries per shard, above which large caches get more shards
+	autoShardsMaxEntries = 1 << 16
+	// autoShardsLimit is the max number of shards picked by auto sharding
+	autoShardsLimit = 4096
+)
+
 var (
 	ErrInvalidConfig = errors.New("invalid config")
 )
 
-type Config[T any] struct {
+// KeyedConfig configures a KeyedCache with keys of type K
+type KeyedConfig[K comparable, V any] struct {
+	hasher            Hasher[K]
+	clock             Clock
+	clockResolution   time.Duration
 	shards            int
 	ttlChecksInterval time.Duration
-	onEvict           func(key string, value T)
+	janitorBudget     int
+	janitorTimeBudget time.Duration
+	onEvict           func(key K, value V)
+	maxEntries        int
+	evictionMode      EvictionMode
+	evictionPolicy    EvictionPolicyFactory[K]
+	tinyLFU           bool
+	lockFreeReads     bool
+	maxCost           int64
+	costFunc          func(key K, value V) int64
+	keyCodec          Codec[K]
+	codec             Codec[V]
+
+	loading loaderConfig[K, V]
+
+	memoryWatchdog      bool
+	memorySoftLimit     int64
+	memoryCheckInterval time.Duration
 }
 
-func NewDefaultConfig[T any]() Config[T] {
-	return Config[T]{
-		shards:            50,
-		ttlChecksInterval: DefaultTtlCheckIntervals,
+// NewKeyedConfig creates the default config for a cache with keys of type K. Keys of string
+// and integer kinds are hashed by a built-in hash, other key types need a hash func from WithKeyHash.
+func NewKeyedConfig[K comparable, V any]() KeyedConfig[K, V] {
+	return KeyedConfig[K, V]{
+		hasher:              newDefaultHasher[K](),
+		clock:               systemClock{},
+		shards:              50,
+		ttlChecksInterval:   DefaultTtlCheckIntervals,
+		janitorBudget:       DefaultJanitorEntriesBudget,
+		janitorTimeBudget:   DefaultJanitorTimeBudget,
+		memoryCheckInterval: DefaultMemoryCheckInterval,
+		keyCodec:            newDefaultKeyCodec[K](),
+		codec:               GobCodec[V]{},
+		loading: loaderConfig[K, V]{
+			ttl: NoExpiration,
+		},
 	}
 }
 
-func (c Config[T]) WithShards(shards int) Config[T] {
+// WithKeyHash sets the hash func of the keys, which spreads them over the shards,
+// it is required for key types other than strings and integers, e.g. structs.
+func (c KeyedConfig[K, V]) WithKeyHash(hash func(key K) uint64) KeyedConfig[K, V] {
+	c.hasher = HashFunc[K](hash)
+	return c
+}
+
+// WithHasher sets the hasher of the keys, e.g. the seeded one from NewMaphashHasher,
+// when the keys come from untrusted input and could be chosen to pile up in a single shard.
+func (c KeyedConfig[K, V]) WithHasher(hasher Hasher[K]) KeyedConfig[K, V] {
+	c.hasher = hasher
+	return c
+}
+
+// WithCodec sets the codec of the values, that turns them into bytes for snapshots, GobCodec is the default.
+func (c KeyedConfig[K, V]) WithCodec(codec Codec[V]) KeyedConfig[K, V] {
+	c.codec = codec
+	return c
+}
+
+// WithKeyCodec sets the codec of the keys, that turns them into bytes for snapshots,
+// it is required for key types other than strings and integers, e.g. structs.
+func (c KeyedConfig[K, V]) WithKeyCodec(codec Codec[K]) KeyedConfig[K, V] {
+	c.keyCodec = codec
+	return c
+}
+
+func (c KeyedConfig[K, V]) WithShards(shards int) KeyedConfig[K, V] {
 	c.shards = shards
 	return c
 }
 
-func (c Config[T]) WithTtlChecksInterval(interval time.Duration) Config[T] {
+// WithAutoShards picks a power of two shard count from runtime.GOMAXPROCS and the expected number of entries,
+// instead of a fixed one. There are enough shards to keep the lock contention low for the available processors,
+// but not so many, that the shards are nearly empty, large caches get more shards, so that the shards stay small.
+// Expected entries of 0 or less mean, that the size is unknown.
+func (c KeyedConfig[K, V]) WithAutoShards(expectedEntries int) KeyedConfig[K, V] {
+	c.shards = autoShards(runtime.GOMAXPROCS(0), expectedEntries)
+	return c
+}
+
+// autoShards returns the power of two shard count for the given number of processors and expected entries
+func autoShards(procs, expectedEntries int) int {
+	shards := nextPowerOfTwo(procs * autoShardsPerProc)
+	if expectedEntries <= 0 {
+		return min(shards, autoShardsLimit)
+	}
+
+	for shards > 1 && expectedEntries/shards < autoShardsMinEntries {
+		shards /= 2
+	}
+
+	for shards < autoShardsLimit && expectedEntries/shards > autoShardsMaxEntries {
+		shards *= 2
+	}
+
+	return min(shards, autoShardsLimit)
+}
+
+// WithLockFreeReads makes Get read the shards without taking any lock, for read-mostly workloads,
+// where the read lock itself becomes contended at high core counts. Every shard keeps an index of its entries,
+// that is read atomically, updates of the existing keys are visible to the reads at once,
+// while the new keys are read under the lock, until the index is rebuilt. Writes get slower and
+// every entry takes more memory. It is not supported together with max entries or max cost.
+func (c KeyedConfig[K, V]) WithLockFreeReads(enabled bool) KeyedConfig[K, V] {
+	c.lockFreeReads = enabled
+	return c
+}
+
+func (c KeyedConfig[K, V]) WithTtlChecksInterval(interval time.Duration) KeyedConfig[K, V] {
 	c.ttlChecksInterval = interval
 	return c
 }
 
-func (c Config[T]) WithOnEvict(f func(key string, value T)) Config[T] {
+// WithJanitorBudget limits the work of a single janitor tick, that cleans expired entries of all the shards.
+// The tick stops after checking the given number of due entries or when the time budget is spent,
+// the remaining expired entries are cleaned by the next ticks.
+func (c KeyedConfig[K, V]) WithJanitorBudget(entries int, timeBudget time.Duration) KeyedConfig[K, V] {
+	c.janitorBudget = entries
+	c.janitorTimeBudget = timeBudget
+	return c
+}
+
+// WithClock sets the source of time for expiration checks, the janitor and the memory watchdog.
+// It is meant for tests, where FakeClock allows to expire entries without waiting.
+func (c KeyedConfig[K, V]) WithClock(clock Clock) KeyedConfig[K, V] {
+	c.clock = clock
+	return c
+}
+
+// WithCoarseClock makes the cache read the time from an atomic, that a background goroutine updates
+// once per resolution, instead of reading the clock on every operation. Expirations are checked
+// up to the resolution late, in exchange for cheaper reads. 0 means that the clock is read on every operation.
+func (c KeyedConfig[K, V]) WithCoarseClock(resolution time.Duration) KeyedConfig[K, V] {
+	c.clockResolution = resolution
+	return c
+}
+
+func (c KeyedConfig[K, V]) WithOnEvict(f func(key K, value V)) KeyedConfig[K, V] {
 	c.onEvict = f
 	return c
 }
 
-func (c Config[T]) validate() error {
+// WithMaxEntries bounds the cache to n entries, split evenly between the shards, so it should not be less than the shards.
+// When a shard exceeds its share, the least recently used entries are evicted
+// and the on evict func is called for each of them. 0 means no limit.
+func (c KeyedConfig[K, V]) WithMaxEntries(n int) KeyedConfig[K, V] {
+	c.maxEntries = n
+	return c
+}
+
+// WithMaxCost bounds the cache by the total cost of its entries, split evenly between the shards,
+// so it should not be less than the shards.
+// Entries are evicted according to the eviction policy, until a new entry fits into its shard.
+// An entry, that costs more than the shard share of max cost, is not stored at all. 0 means no limit.
+func (c KeyedConfig[K, V]) WithMaxCost(n int64) KeyedConfig[K, V] {
+	c.maxCost = n
+	return c
+}
+
+// WithCostFunc sets the func that computes the cost of an entry, e.g. its size in bytes.
+// Without it every entry costs 1, unless the cost is given explicitly with SetWithCost. Negative costs count as 0.
+func (c KeyedConfig[K, V]) WithCostFunc(f func(key K, value V) int64) KeyedConfig[K, V] {
+	c.costFunc = f
+	return c
+}
+
+// WithLoader makes the cache a loading cache, Fetch loads the missing keys with the loader
+// and stores them with the loader ttl.
+func (c KeyedConfig[K, V]) WithLoader(loader func(ctx context.Context, key K) (V, error)) KeyedConfig[K, V] {
+	c.loading.loader = loader
+	return c
+}
+
+// WithLoadAll sets the func, that FetchAll uses to load all the missing keys in one call,
+// keys that should not be cached are expected to be absent from the returned map.
+func (c KeyedConfig[K, V]) WithLoadAll(loadAll func(ctx context.Context, keys []K) (map[K]V, error)) KeyedConfig[K, V] {
+	c.loading.loadAll = loadAll
+	return c
+}
+
+// WithLoaderTtl sets the ttl of the loaded values, NoExpiration is the default.
+func (c KeyedConfig[K, V]) WithLoaderTtl(ttl time.Duration) KeyedConfig[K, V] {
+	c.loading.ttl = ttl
+	return c
+}
+
+// WithRefreshAfterWrite makes reads of a loaded value, that is older than the given duration,
+// trigger a reload in the background, while the current value keeps being returned.
+// It applies to the values loaded by Fetch and FetchAll.
+func (c KeyedConfig[K, V]) WithRefreshAfterWrite(d time.Duration) KeyedConfig[K, V] {
+	c.loading.refreshAfter = d
+	return c
+}
+
+// WithNegativeTtl caches failed loads of Fetch, FetchAll and GetOrLoad for the given ttl, so that
+// the loader is not called for the key again until the ttl has passed. Consecutive failures of the same key
+// double the ttl up to max ttl, max ttl of 0 disables the backoff. Context errors are never cached.
+func (c KeyedConfig[K, V]) WithNegativeTtl(ttl, maxTtl time.Duration) KeyedConfig[K, V] {
+	c.loading.negativeTtl = ttl
+	c.loading.negativeMaxTtl = maxTtl
+	return c
+}
+
+// WithStaleWhileRevalidate keeps loaded values for the given window after their loader ttl has passed.
+// Reads of a stale value return it and trigger a reload in the background.
+// It applies to the values loaded by Fetch and FetchAll.
+func (c KeyedConfig[K, V]) WithStaleWhileRevalidate(window time.Duration) KeyedConfig[K, V] {
+	c.loading.staleWindow = window
+	return c
+}
+
+// WithMemoryWatchdog enables the watchdog, that evicts a share of entries from every shard,
+// when the live heap exceeds the soft limit in bytes. Soft limit of 0 means that the watchdog
+// starts evicting close to the runtime memory limit, set by GOMEMLIMIT or debug.SetMemoryLimit.
+func (c KeyedConfig[K, V]) WithMemoryWatchdog(softLimit int64) KeyedConfig[K, V] {
+	c.memoryWatchdog = true
+	c.memorySoftLimit = softLimit
+	return c
+}
+
+// WithMemoryCheckInterval sets how often the memory watchdog reads the heap metrics
+func (c KeyedConfig[K, V]) WithMemoryCheckInterval(interval time.Duration) KeyedConfig[K, V] {
+	c.memoryCheckInterval = interval
+	return c
+}
+
+// WithEvictionMode selects the built-in eviction policy used when max entries or max cost is reached,
+// EvictLRU is the default.
+func (c KeyedConfig[K, V]) WithEvictionMode(mode EvictionMode) KeyedConfig[K, V] {
+	c.evictionMode = mode
+	return c
+}
+
+// WithEvictionPolicy sets a custom eviction policy used when max entries or max cost is reached,
+// the factory is called once per shard. It takes precedence over the eviction mode.
+func (c KeyedConfig[K, V]) WithEvictionPolicy(factory EvictionPolicyFactory[K]) KeyedConfig[K, V] {
+	c.evictionPolicy = factory
+	return c
+}
+
+// WithTinyLFU enables TinyLFU admission filter for bounded caches. When a shard is full,
+// a new key is admitted only if it is estimated to be accessed more often than the eviction victim,
+// otherwise the new key is dropped and the victim stays in the cache.
+func (c KeyedConfig[K, V]) WithTinyLFU(enabled bool) KeyedConfig[K, V] {
+	c.tinyLFU = enabled
+	return c
+}
+
+func (c KeyedConfig[K, V]) policyFactory() EvictionPolicyFactory[K] {
+	if c.evictionPolicy != nil {
+		return c.evictionPolicy
+	}
+
+	factory, _ := builtinPolicy[K](c.evictionMode)
+	return factory
+}
+
+// validateBounds checks, that every one of the shards gets a share of max entries and max cost,
+// a shard without a share would be unbounded
+func (c KeyedConfig[K, V]) validateBounds(shards int) error {
+	if c.maxEntries > 0 && c.maxEntries < shards {
+		return fmt.Errorf("%w: max entries %d should not be less than %d shards", ErrInvalidConfig, c.maxEntries, shards)
+	}
+
+	if c.maxCost > 0 && c.maxCost < int64(shards) {
+		return fmt.Errorf("%w: max cost %d should not be less than %d shards", ErrInvalidConfig, c.maxCost, shards)
+	}
+
+	return nil
+}
+
+func (c KeyedConfig[K, V]) validate() error {
+	if c.hasher == nil {
+		return fmt.Errorf("%w: key hash is required for key type %T", ErrInvalidConfig, *new(K))
+	}
+
+	if c.clock == nil {
+		return fmt.Errorf("%w: clock is required", ErrInvalidConfig)
+	}
+
+	if c.clockResolution < 0 {
+		return fmt.Errorf("%w: clock resolution should not be negative", ErrInvalidConfig)
+	}
+
 	if c.shards < 1 {
 		return fmt.Errorf("%w: shards should be greater or equal to 1", ErrInvalidConfig)
 	}
 
+	if c.janitorBudget < 1 || c.janitorTimeBudget <= 0 {
+		return fmt.Errorf("%w: janitor budget should be positive", ErrInvalidConfig)
+	}
+
+	if c.maxEntries < 0 {
+		return fmt.Errorf("%w: max entries should not be negative", ErrInvalidConfig)
+	}
+
+	if c.maxCost < 0 {
+		return fmt.Errorf("%w: max cost should not be negative", ErrInvalidConfig)
+	}
+
+	if err := c.validateBounds(c.shards); err != nil {
+		return err
+	}
+
+	if c.loading.refreshAfter < 0 || c.loading.staleWindow < 0 {
+		return fmt.Errorf("%w: refresh after write and stale window should not be negative", ErrInvalidConfig)
+	}
+
+	if c.loading.negativeTtl < 0 || c.loading.negativeMaxTtl < 0 {
+		return fmt.Errorf("%w: negative ttl should not be negative", ErrInvalidConfig)
+	}
+
+	if c.loading.negativeMaxTtl > 0 && c.loading.negativeMaxTtl < c.loading.negativeTtl {
+		return fmt.Errorf("%w: negative max ttl should not be less than negative ttl", ErrInvalidConfig)
+	}
+
+	if c.lockFreeReads && (c.maxEntries > 0 || c.maxCost > 0) {
+		return fmt.Errorf("%w: lock free reads are not supported by caches bounded by max entries or max cost", ErrInvalidConfig)
+	}
+
+	if c.memorySoftLimit < 0 {
+		return fmt.Errorf("%w: memory soft limit should not be negative", ErrInvalidConfig)
+	}
+
+	if c.memoryWatchdog && c.memoryCheckInterval <= 0 {
+		return fmt.Errorf("%w: memory check interval should be positive", ErrInvalidConfig)
+	}
+
+	if _, ok := builtinPolicy[K](c.evictionMode); !ok {
+		return fmt.Errorf("%w: unknown eviction mode %d", ErrInvalidConfig, c.evictionMode)
+	}
+
 	return nil
 }
+
+// Config configures a Cache, the string keyed variant of KeyedCache.
+// Its methods are the same as the ones of KeyedConfig.
+type Config[V any] struct {
+	keyed KeyedConfig[string, V]
+}
+
+func NewDefaultConfig[V any]() Config[V] {
+	return Config[V]{keyed: NewKeyedConfig[string, V]()}
+}
+
+func (c Config[V]) WithKeyHash(hash func(key string) uint64) Config[V] {
+	return Config[V]{keyed: c.keyed.WithKeyHash(hash)}
+}
+
+func (c Config[V]) WithHasher(hasher Hasher[string]) Config[V] {
+	return Config[V]{keyed: c.keyed.WithHasher(hasher)}
+}
+
+func (c Config[V]) WithCodec(codec Codec[V]) Config[V] {
+	return Config[V]{keyed: c.keyed.WithCodec(codec)}
+}
+
+func (c Config[V]) WithKeyCodec(codec Codec[string]) Config[V] {
+	return Config[V]{keyed: c.keyed.WithKeyCodec(codec)}
+}
+
+func (c Config[V]) WithShards(shards int) Config[V] {
+	return Config[V]{keyed: c.keyed.WithShards(shards)}
+}
+
+func (c Config[V]) WithAutoShards(expectedEntries int) Config[V] {
+	return Config[V]{keyed: c.keyed.WithAutoShards(expectedEntries)}
+}
+
+func (c Config[V]) WithLockFreeReads(enabled bool) Config[V] {
+	return Config[V]{keyed: c.keyed.WithLockFreeReads(enabled)}
+}
+
+func (c Config[V]) WithTtlChecksInterval(interval time.Duration) Config[V] {
+	return Config[V]{keyed: c.keyed.WithTtlChecksInterval(interval)}
+}
+
+func (c Config[V]) WithJanitorBudget(entries int, timeBudget time.Duration) Config[V] {
+	return Config[V]{keyed: c.keyed.WithJanitorBudget(entries, timeBudget)}
+}
+
+func (c Config[V]) WithClock(clock Clock) Config[V] {
+	return Config[V]{keyed: c.keyed.WithClock(clock)}
+}
+
+func (c Config[V]) WithCoarseClock(resolution time.Duration) Config[V] {
+	return Config[V]{keyed: c.keyed.WithCoarseClock(resolution)}
+}
+
+func (c Config[V]) WithOnEvict(f func(key string, value V)) Config[V] {
+	return Config[V]{keyed: c.keyed.WithOnEvict(f)}
+}
+
+func (c Config[V]) WithMaxEntries(n int) Config[V] {
+	return Config[V]{keyed: c.keyed.WithMaxEntries(n)}
+}
+
+func (c Config[V]) WithMaxCost(n int64) Config[V] {
+	return Config[V]{keyed: c.keyed.WithMaxCost(n)}
+}
+
+func (c Config[V]) WithCostFunc(f func(key string, value V) int64) Config[V] {
+	return Config[V]{keyed: c.keyed.WithCostFunc(f)}
+}
+
+func (c Config[V]) WithLoader(loader func(ctx context.Context, key string) (V, error)) Config[V] {
+	return Config[V]{keyed: c.keyed.WithLoader(loader)}
+}
+
+func (c Config[V]) WithLoadAll(loadAll func(ctx context.Context, keys []string) (map[string]V, error)) Config[V] {
+	return Config[V]{keyed: c.keyed.WithLoadAll(loadAll)}
+}
+
+func (c Config[V]) WithLoaderTtl(ttl time.Duration) Config[V] {
+	return Config[V]{keyed: c.keyed.WithLoaderTtl(ttl)}
+}
+
+func (c Config[V]) WithRefreshAfterWrite(d time.Duration) Config[V] {
+	return Config[V]{keyed: c.keyed.WithRefreshAfterWrite(d)}
+}
+
+func (c Config[V]) WithNegativeTtl(ttl, maxTtl time.Duration) Config[V] {
+	return Config[V]{keyed: c.keyed.WithNegativeTtl(ttl, maxTtl)}
+}
+
+func (c Config[V]) WithStaleWhileRevalidate(window time.Duration) Config[V] {
+	return Config[V]{keyed: c.keyed.WithStaleWhileRevalidate(window)}
+}
+
+func (c Config[V]) WithMemoryWatchdog(softLimit int64) Config[V] {
+	return Config[V]{keyed: c.keyed.WithMemoryWatchdog(softLimit)}
+}
+
+func (c Config[V]) WithMemoryCheckInterval(interval time.Duration) Config[V] {
+	return Config[V]{keyed: c.keyed.WithMemoryCheckInterval(interval)}
+}
+
+func (c Config[V]) WithEvictionMode(mode EvictionMode) Config[V] {
+	return Config[V]{keyed: c.keyed.WithEvictionMode(mode)}
+}
+
+func (c Config[V]) WithEvictionPolicy(factory EvictionPolicyFactory[string]) Config[V] {
+	return Config[V]{keyed: c.keyed.WithEvictionPolicy(factory)}
+}
+
+func (c Config[V]) WithTinyLFU(enabled bool) Config[V] {
+	return Config[V]{keyed: c.keyed.WithTinyLFU(enabled)}
+}
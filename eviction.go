@@ -0,0 +1,57 @@
+package litecache
+
+// EvictionPolicy decides which key is evicted from a shard, when the shard reaches its capacity.
+// Every shard gets its own instance of the policy and calls it under the shard lock,
+// so implementations do not need to be safe for concurrent use.
+type EvictionPolicy[K comparable] interface {
+	// OnAccess is called when an existing key is read, updated or transformed
+	OnAccess(key K)
+	// OnInsert is called when a new key is added to the shard
+	OnInsert(key K)
+	// OnRemove is called when a key leaves the shard, because it was removed, has expired or was evicted
+	OnRemove(key K)
+	// Victim returns the key that should be evicted next,
+	// false means that the policy has nothing to evict
+	Victim() (K, bool)
+}
+
+// ReadAccessPolicy is implemented by eviction policies, that can register a read
+// while the shard is only locked for reading. Shards using such policy serve reads on the read lock,
+// so OnReadAccess must be safe to call concurrently with other OnReadAccess calls.
+// All the other methods are still called under the shard write lock.
+type ReadAccessPolicy[K comparable] interface {
+	EvictionPolicy[K]
+	// OnReadAccess is called when an existing key is read
+	OnReadAccess(key K)
+}
+
+// EvictionPolicyFactory creates an eviction policy for a shard with the given capacity
+type EvictionPolicyFactory[K comparable] func(capacity int) EvictionPolicy[K]
+
+// EvictionMode selects one of the built-in eviction policies
+type EvictionMode int
+
+const (
+	// EvictLRU evicts the least recently used entries
+	EvictLRU EvictionMode = iota
+	// EvictLFU evicts the least frequently used entries, frequencies decay over time
+	// so that entries which used to be popular do not stay in the cache forever
+	EvictLFU
+	// EvictSIEVE evicts entries in FIFO order, but gives the entries read since the last pass a second chance.
+	// Reads only flip a visited flag, so unlike LRU and LFU they do not need the shard write lock.
+	EvictSIEVE
+)
+
+// builtinPolicy returns the factory of the built-in policy selected by the mode
+func builtinPolicy[K comparable](m EvictionMode) (EvictionPolicyFactory[K], bool) {
+	switch m {
+	case EvictLRU:
+		return NewLRUPolicy[K], true
+	case EvictLFU:
+		return NewLFUPolicy[K], true
+	case EvictSIEVE:
+		return NewSIEVEPolicy[K], true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,785 @@
+package litecache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denismitr/litecache"
+)
+
+func TestCache_MaxEntries(t *testing.T) {
+	t.Parallel()
+
+	t.Run("negative max entries", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().WithMaxEntries(-1)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, litecache.ErrInvalidConfig))
+		require.Nil(t, c)
+	})
+
+	t.Run("least recently used keys are evicted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		evicted := make(map[string]int)
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxEntries(3).
+			WithOnEvict(func(key string, value int) {
+				evicted[key] = value
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Set("c", 3)
+
+		// touch a so that b becomes the least recently used
+		{
+			v, found := c.Get("a")
+			assert.True(t, found)
+			assert.Equal(t, 1, v)
+		}
+
+		c.Set("d", 4)
+		assert.Equal(t, 3, c.Count())
+		assert.Equal(t, 3, c.CountPrecise())
+		assert.Equal(t, map[string]int{"b": 2}, evicted)
+
+		{
+			_, found := c.Get("b")
+			assert.False(t, found)
+		}
+
+		assert.True(t, c.SetNx("e", 5))
+		assert.Equal(t, map[string]int{"b": 2, "c": 3}, evicted)
+		assert.Equal(t, 3, c.Count())
+
+		for k, expected := range map[string]int{"a": 1, "d": 4, "e": 5} {
+			v, found := c.Get(k)
+			assert.True(t, found)
+			assert.Equal(t, expected, v)
+		}
+	})
+
+	t.Run("updates do not evict", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().WithShards(1).WithMaxEntries(2)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Set("a", 10)
+		assert.True(t, c.SetEx("b", 20))
+		assert.Equal(t, 2, c.Count())
+
+		c.Set("c", 3)
+		assert.Equal(t, 2, c.Count())
+
+		_, found := c.Get("a")
+		assert.False(t, found)
+	})
+
+	t.Run("bounded by shard share", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		const maxEntries = 1_000
+		cfg := litecache.NewDefaultConfig[int]().WithShards(8).WithMaxEntries(maxEntries)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 100_000; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), i)
+		}
+
+		assert.LessOrEqual(t, c.CountPrecise(), maxEntries)
+		assert.Equal(t, c.CountPrecise(), c.Count())
+	})
+
+	t.Run("bounded with default shards", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithMaxEntries(10))
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+		require.Nil(t, c)
+
+		_, err = litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithMaxCost(10))
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+
+		// 120 entries do not split evenly between the 50 default shards
+		const maxEntries = 120
+		c, err = litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithMaxEntries(maxEntries))
+		require.NoError(t, err)
+
+		for i := 0; i < 10_000; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), i)
+		}
+
+		assert.Equal(t, maxEntries, c.CountPrecise())
+		assert.Equal(t, maxEntries, c.Count())
+
+		var total int
+		for _, s := range c.ShardStats() {
+			assert.LessOrEqual(t, s.Entries, 3)
+			total += s.Entries
+		}
+		assert.Equal(t, maxEntries, total)
+
+		require.ErrorIs(t, c.Reshard(maxEntries+1), litecache.ErrInvalidConfig)
+	})
+}
+
+func TestCache_LFU(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unknown eviction mode", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().WithEvictionMode(litecache.EvictionMode(100))
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, litecache.ErrInvalidConfig))
+		require.Nil(t, c)
+	})
+
+	t.Run("least frequently used keys are evicted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var evicted []string
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxEntries(3).
+			WithEvictionMode(litecache.EvictLFU).
+			WithOnEvict(func(key string, value int) {
+				evicted = append(evicted, key)
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Set("c", 3)
+
+		for i := 0; i < 3; i++ {
+			c.Get("a")
+			c.Get("c")
+		}
+		assert.True(t, c.Transform("b", func(v int) int { return v * 10 }))
+		c.Get("a")
+
+		// b was accessed once, so it is the least frequently used
+		c.Set("d", 4)
+		assert.Equal(t, []string{"b"}, evicted)
+		assert.Equal(t, 3, c.Count())
+
+		// d was never accessed, so it is evicted before a and c
+		c.Set("e", 5)
+		assert.Equal(t, []string{"b", "d"}, evicted)
+	})
+
+	t.Run("hot keys survive a scan", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxEntries(100).
+			WithEvictionMode(litecache.EvictLFU)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 50; i++ {
+			c.Set(fmt.Sprintf("hot:%d", i), i)
+		}
+
+		for round := 0; round < 5; round++ {
+			for i := 0; i < 50; i++ {
+				c.Get(fmt.Sprintf("hot:%d", i))
+			}
+		}
+
+		for i := 0; i < 10_000; i++ {
+			c.Set(fmt.Sprintf("scan:%d", i), i)
+		}
+
+		assert.Equal(t, 100, c.CountPrecise())
+		for i := 0; i < 50; i++ {
+			v, found := c.Get(fmt.Sprintf("hot:%d", i))
+			assert.True(t, found)
+			assert.Equal(t, i, v)
+		}
+	})
+}
+
+// priorityPolicy evicts low priority keys first, then falls back to insertion order
+type priorityPolicy struct {
+	keys    []string
+	removed []string
+}
+
+func (p *priorityPolicy) OnAccess(string) {}
+
+func (p *priorityPolicy) OnInsert(key string) {
+	p.keys = append(p.keys, key)
+}
+
+func (p *priorityPolicy) OnRemove(key string) {
+	p.removed = append(p.removed, key)
+	for i, k := range p.keys {
+		if k == key {
+			p.keys = append(p.keys[:i], p.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *priorityPolicy) Victim() (string, bool) {
+	for _, k := range p.keys {
+		if strings.HasPrefix(k, "low:") {
+			return k, true
+		}
+	}
+
+	if len(p.keys) == 0 {
+		return "", false
+	}
+	return p.keys[0], true
+}
+
+func TestCache_EvictionPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("custom policy", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		policy := &priorityPolicy{}
+		var evicted []string
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxEntries(3).
+			WithEvictionPolicy(func(capacity int) litecache.EvictionPolicy[string] {
+				assert.Equal(t, 3, capacity)
+				return policy
+			}).
+			WithOnEvict(func(key string, value int) {
+				evicted = append(evicted, key)
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("high:1", 1)
+		c.Set("low:1", 2)
+		c.Set("high:2", 3)
+		c.Set("high:3", 4)
+		assert.Equal(t, []string{"low:1"}, evicted)
+
+		c.Set("high:4", 5)
+		assert.Equal(t, []string{"low:1", "high:1"}, evicted)
+
+		assert.True(t, c.Remove("high:2"))
+		assert.Equal(t, []string{"low:1", "high:1", "high:2"}, policy.removed)
+		assert.Equal(t, []string{"high:3", "high:4"}, policy.keys)
+	})
+
+	t.Run("policy takes precedence over mode", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxEntries(2).
+			WithEvictionMode(litecache.EvictLFU).
+			WithEvictionPolicy(litecache.NewLRUPolicy[string])
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Get("a")
+		c.Get("a")
+		c.Get("b")
+		c.Set("c", 3)
+
+		_, found := c.Get("a")
+		assert.False(t, found)
+	})
+}
+
+func TestCache_TinyLFU(t *testing.T) {
+	t.Parallel()
+
+	t.Run("hot keys survive a scan", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxEntries(100).
+			WithTinyLFU(true)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 100; i++ {
+			c.Set(fmt.Sprintf("hot:%d", i), i)
+		}
+
+		for round := 0; round < 3; round++ {
+			for i := 0; i < 100; i++ {
+				c.Get(fmt.Sprintf("hot:%d", i))
+			}
+		}
+
+		for i := 0; i < 500; i++ {
+			c.Set(fmt.Sprintf("scan:%d", i), i)
+		}
+
+		assert.Equal(t, 100, c.CountPrecise())
+		assert.Equal(t, 100, c.Count())
+
+		hits := 0
+		for i := 0; i < 100; i++ {
+			if _, found := c.Get(fmt.Sprintf("hot:%d", i)); found {
+				hits++
+			}
+		}
+		assert.GreaterOrEqual(t, hits, 95)
+	})
+
+	t.Run("frequently requested key gets admitted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxEntries(10).
+			WithTinyLFU(true)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), i)
+		}
+
+		// a new key is rejected when it was never seen before
+		assert.False(t, c.SetNx("new", 1))
+
+		admitted := false
+		for i := 0; i < 5 && !admitted; i++ {
+			if _, found := c.Get("new"); !found {
+				admitted = c.SetNx("new", 1)
+			}
+		}
+
+		assert.True(t, admitted)
+		v, found := c.Get("new")
+		assert.True(t, found)
+		assert.Equal(t, 1, v)
+		assert.Equal(t, 10, c.Count())
+	})
+}
+
+func TestCache_SIEVE(t *testing.T) {
+	t.Parallel()
+
+	t.Run("visited keys get a second chance", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var evicted []string
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxEntries(3).
+			WithEvictionMode(litecache.EvictSIEVE).
+			WithOnEvict(func(key string, value int) {
+				evicted = append(evicted, key)
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Set("c", 3)
+		c.Get("a")
+		c.Get("c")
+
+		c.Set("d", 4)
+		assert.Equal(t, []string{"b"}, evicted)
+
+		// c lost its visited flag when the hand passed it, but d was never visited
+		c.Set("e", 5)
+		assert.Equal(t, []string{"b", "d"}, evicted)
+
+		// the hand starts over from the tail, where a has lost its visited flag too
+		c.Get("c")
+		c.Set("f", 6)
+		assert.Equal(t, []string{"b", "d", "a"}, evicted)
+		assert.Equal(t, 3, c.Count())
+
+		for k, expected := range map[string]int{"c": 3, "e": 5, "f": 6} {
+			v, found := c.Get(k)
+			assert.True(t, found)
+			assert.Equal(t, expected, v)
+		}
+	})
+
+	t.Run("concurrent reads and writes", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		const maxEntries = 1_000
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(4).
+			WithMaxEntries(maxEntries).
+			WithEvictionMode(litecache.EvictSIEVE).
+			WithTinyLFU(true)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 10_000; i++ {
+					key := fmt.Sprintf("key:%d", (i*(w+1))%3_000)
+					if _, found := c.Get(key); !found {
+						c.Set(key, i)
+					}
+				}
+			}(w)
+		}
+
+		wg.Wait()
+		assert.LessOrEqual(t, c.CountPrecise(), maxEntries)
+		assert.Equal(t, c.CountPrecise(), c.Count())
+	})
+}
+
+func TestCache_MaxCost(t *testing.T) {
+	t.Parallel()
+
+	t.Run("negative max cost", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[string]().WithMaxCost(-1)
+
+		c, err := litecache.NewWithConfig[string](ctx, cfg)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, litecache.ErrInvalidConfig))
+		require.Nil(t, c)
+	})
+
+	t.Run("evicts until the new value fits", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var evicted []string
+		cfg := litecache.NewDefaultConfig[string]().
+			WithShards(1).
+			WithMaxCost(10).
+			WithCostFunc(func(key string, value string) int64 {
+				return int64(len(value))
+			}).
+			WithOnEvict(func(key string, value string) {
+				evicted = append(evicted, key)
+			})
+
+		c, err := litecache.NewWithConfig[string](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("a", "1234")
+		c.Set("b", "123")
+		c.Set("c", "12")
+		assert.Equal(t, int64(9), c.Cost())
+		assert.Empty(t, evicted)
+
+		c.Set("d", "12345")
+		assert.Equal(t, []string{"a"}, evicted)
+		assert.Equal(t, int64(10), c.Cost())
+		assert.Equal(t, 3, c.Count())
+
+		// growing an existing value evicts others, but not the value itself
+		c.Set("d", "123456789")
+		assert.Equal(t, []string{"a", "b", "c"}, evicted)
+		assert.Equal(t, int64(9), c.Cost())
+
+		v, found := c.Get("d")
+		assert.True(t, found)
+		assert.Equal(t, "123456789", v)
+	})
+
+	t.Run("value costlier than max cost is not stored", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[string]().WithShards(1).WithMaxCost(10)
+
+		c, err := litecache.NewWithConfig[string](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("a", "foo")
+		c.SetWithCost("b", "bar", 11)
+		assert.Equal(t, 1, c.Count())
+		assert.Equal(t, int64(1), c.Cost())
+
+		_, found := c.Get("b")
+		assert.False(t, found)
+
+		// an update that does not fit removes the old value
+		c.SetWithCost("a", "baz", 11)
+		_, found = c.Get("a")
+		assert.False(t, found)
+		assert.Equal(t, 0, c.Count())
+		assert.Equal(t, int64(0), c.Cost())
+	})
+
+	t.Run("costlier update of the lfu victim is rejected", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var evicted []string
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxCost(10).
+			WithEvictionMode(litecache.EvictLFU).
+			WithOnEvict(func(key string, value int) {
+				evicted = append(evicted, key)
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.SetWithCost("a", 1, 5)
+		c.SetWithCost("b", 1, 5)
+		for i := 0; i < 10; i++ {
+			c.Get("b")
+		}
+
+		// "a" is the victim itself, so it can not make room for its own update
+		c.SetWithCost("a", 2, 8)
+		assert.Equal(t, int64(5), c.Cost())
+		assert.Equal(t, []string{"a"}, evicted)
+
+		_, found := c.Get("a")
+		assert.False(t, found)
+		_, found = c.Get("b")
+		assert.True(t, found)
+	})
+
+	t.Run("tinylfu rejects before evicting any victim", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var evicted []string
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxCost(10).
+			WithTinyLFU(true).
+			WithOnEvict(func(key string, value int) {
+				evicted = append(evicted, key)
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.SetWithCost("cold", 1, 5)
+		c.SetWithCost("hot", 2, 5)
+		for i := 0; i < 20; i++ {
+			c.Get("hot")
+		}
+
+		// "new" is more popular than "cold", but not than "hot", and needs the room of both
+		for i := 0; i < 3; i++ {
+			c.Get("new")
+		}
+		c.SetWithCost("new", 3, 10)
+
+		_, found := c.Get("new")
+		assert.False(t, found)
+		assert.Empty(t, evicted)
+		assert.Equal(t, 2, c.Count())
+		assert.Equal(t, int64(10), c.Cost())
+
+		// the restored victims are still evicted as usual
+		for i := 0; i < 5; i++ {
+			c.Get("next")
+		}
+		c.SetWithCost("next", 4, 5)
+		assert.Equal(t, []string{"cold"}, evicted)
+		assert.Equal(t, int64(10), c.Cost())
+	})
+
+	t.Run("negative costs count as zero", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithMaxCost(10).
+			WithCostFunc(func(key string, value int) int64 {
+				return int64(value)
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.SetWithCost("a", 1, -1000)
+		c.SetTtlWithCost("b", 2, time.Minute, -1)
+		c.Set("c", -500)
+		assert.Equal(t, int64(0), c.Cost())
+
+		for i := 1; i <= 20; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), 1)
+		}
+		assert.Equal(t, int64(10), c.Cost())
+		assert.LessOrEqual(t, c.Count(), 13)
+	})
+
+	t.Run("explicit cost is kept on transform", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().WithShards(1).WithMaxCost(100)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.SetTtlWithCost("a", 1, time.Minute, 40)
+		c.SetWithCost("b", 2, 50)
+		assert.True(t, c.Transform("a", func(v int) int { return v + 1 }))
+		assert.Equal(t, int64(90), c.Cost())
+
+		assert.True(t, c.Remove("b"))
+		assert.Equal(t, int64(40), c.Cost())
+	})
+
+	t.Run("bounded by shard share", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		const maxCost = 64 * 1024
+		cfg := litecache.NewDefaultConfig[[]byte]().
+			WithShards(8).
+			WithMaxCost(maxCost).
+			WithEvictionMode(litecache.EvictSIEVE).
+			WithCostFunc(func(key string, value []byte) int64 {
+				return int64(len(key) + len(value))
+			})
+
+		c, err := litecache.NewWithConfig[[]byte](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 10_000; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), make([]byte, i%512))
+		}
+
+		assert.LessOrEqual(t, c.Cost(), int64(maxCost))
+		assert.Greater(t, c.Cost(), int64(maxCost/2))
+		assert.Equal(t, c.CountPrecise(), c.Count())
+	})
+}
+
+func TestCache_MemoryWatchdog(t *testing.T) {
+	t.Parallel()
+
+	t.Run("negative soft limit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().WithMemoryWatchdog(-1)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, litecache.ErrInvalidConfig))
+		require.Nil(t, c)
+	})
+
+	t.Run("evicts entries when heap exceeds the soft limit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		const N = 10_000
+		var evicted atomic.Int64
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(8).
+			WithMaxEntries(N).
+			WithMemoryWatchdog(1).
+			WithMemoryCheckInterval(10 * time.Millisecond).
+			WithOnEvict(func(key string, value int) {
+				evicted.Add(1)
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < N; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), i)
+		}
+
+		require.Eventually(t, func() bool {
+			runtime.GC()
+			return c.Count() < N/2
+		}, 5*time.Second, 20*time.Millisecond)
+
+		assert.Equal(t, c.CountPrecise(), c.Count())
+		assert.Equal(t, int64(N-c.Count()), evicted.Load())
+	})
+
+	t.Run("does nothing below the soft limit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().
+			WithMemoryWatchdog(math.MaxInt64 / 2).
+			WithMemoryCheckInterval(10 * time.Millisecond)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 1_000; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), i)
+		}
+
+		runtime.GC()
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, 1_000, c.CountPrecise())
+	})
+}
@@ -0,0 +1,31 @@
+package litecache
+
+// expiryCompactionSlack keeps small shards from rebuilding the expiry heap too often
+const expiryCompactionSlack = 64
+
+// expiryEntry schedules an expiration check of the key at exp
+type expiryEntry[K comparable] struct {
+	key K
+	exp int64
+}
+
+// expiryHeap is a min heap of scheduled expirations, entries are never removed from the middle,
+// instead the shard discards entries that no longer match the schedule of their item when they are due
+type expiryHeap[K comparable] []expiryEntry[K]
+
+func (h expiryHeap[K]) Len() int           { return len(h) }
+func (h expiryHeap[K]) Less(i, j int) bool { return h[i].exp < h[j].exp }
+func (h expiryHeap[K]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap[K]) Push(x any) {
+	*h = append(*h, x.(expiryEntry[K]))
+}
+
+func (h *expiryHeap[K]) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = expiryEntry[K]{}
+	*h = old[:n-1]
+	return e
+}
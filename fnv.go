@@ -1,9 +1,5 @@
 package litecache
 
-func newDefaultHasher() hasher {
-	return fnv64a{}
-}
-
 type fnv64a struct{}
 
 const (
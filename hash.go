@@ -1,5 +1,122 @@
 package litecache
 
-type hasher interface {
-	Hash(string) uint64
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"reflect"
+	"unsafe"
+)
+
+// Hasher spreads the keys over the shards, it has to return the same hash for equal keys
+// and is called concurrently, so implementations must be safe for concurrent use.
+type Hasher[K comparable] interface {
+	Hash(key K) uint64
+}
+
+// HashFunc is a hash of the keys supplied by the user, e.g. for struct keys
+type HashFunc[K comparable] func(key K) uint64
+
+func (f HashFunc[K]) Hash(key K) uint64 {
+	return f(key)
+}
+
+// newDefaultHasher returns the built-in hasher for the keys of string and integer kinds,
+// including the named types like `type UserID int64`, and nil for other key types
+func newDefaultHasher[K comparable]() Hasher[K] {
+	switch keyKind[K]() {
+	case reflect.String:
+		return stringHasher[K]{}
+	case reflect.Int:
+		return intHasher[K]{}
+	default:
+		return nil
+	}
+}
+
+// NewMaphashHasher creates a hasher of the keys of string and integer kinds based on hash/maphash
+// with a random seed, so that the shards of the keys can not be predicted by someone who controls the keys.
+// Hashes differ between processes and between hashers. It returns nil for other key types.
+func NewMaphashHasher[K comparable]() Hasher[K] {
+	seed := maphash.MakeSeed()
+	switch keyKind[K]() {
+	case reflect.String:
+		return maphashStringHasher[K]{seed: seed}
+	case reflect.Int:
+		return maphashIntHasher[K]{seed: seed}
+	default:
+		return nil
+	}
+}
+
+// keyKind returns reflect.String for the keys of string kinds, reflect.Int for the keys of all integer kinds
+// and reflect.Invalid for the rest
+func keyKind[K comparable]() reflect.Kind {
+	switch reflect.TypeOf((*K)(nil)).Elem().Kind() {
+	case reflect.String:
+		return reflect.String
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Int
+	default:
+		return reflect.Invalid
+	}
+}
+
+// stringHasher hashes keys of string kind with fnv64a
+type stringHasher[K comparable] struct{}
+
+func (stringHasher[K]) Hash(key K) uint64 {
+	// K is of string kind, so it has the memory layout of a string
+	return fnv64a{}.Hash(*(*string)(unsafe.Pointer(&key)))
+}
+
+// intHasher hashes keys of integer kinds, reading them as unsigned integers
+// of the same size, which avoids boxing the keys into interfaces
+type intHasher[K comparable] struct{}
+
+func (intHasher[K]) Hash(key K) uint64 {
+	return mix64(intBits(key))
+}
+
+// intBits returns the bits of a key of an integer kind
+func intBits[K comparable](key K) uint64 {
+	p := unsafe.Pointer(&key)
+	switch unsafe.Sizeof(key) {
+	case 1:
+		return uint64(*(*uint8)(p))
+	case 2:
+		return uint64(*(*uint16)(p))
+	case 4:
+		return uint64(*(*uint32)(p))
+	default:
+		return *(*uint64)(p)
+	}
+}
+
+type maphashStringHasher[K comparable] struct {
+	seed maphash.Seed
+}
+
+func (h maphashStringHasher[K]) Hash(key K) uint64 {
+	return maphash.String(h.seed, *(*string)(unsafe.Pointer(&key)))
+}
+
+type maphashIntHasher[K comparable] struct {
+	seed maphash.Seed
+}
+
+func (h maphashIntHasher[K]) Hash(key K) uint64 {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], intBits(key))
+	return maphash.Bytes(h.seed, b[:])
+}
+
+// mix64 is the splitmix64 finalizer, sequential integers end up in different shards
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
 }
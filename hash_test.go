@@ -0,0 +1,99 @@
+package litecache_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denismitr/litecache"
+)
+
+type constantHasher struct{}
+
+func (constantHasher) Hash(string) uint64 { return 7 }
+
+func TestMaphashHasher(t *testing.T) {
+	t.Parallel()
+
+	t.Run("strings", func(t *testing.T) {
+		h1 := litecache.NewMaphashHasher[string]()
+		h2 := litecache.NewMaphashHasher[string]()
+		require.NotNil(t, h1)
+		require.NotNil(t, h2)
+
+		assert.Equal(t, h1.Hash("foo"), h1.Hash("f"+"oo"))
+		assert.NotEqual(t, h1.Hash("foo"), h1.Hash("bar"))
+		// hashers are seeded independently
+		assert.NotEqual(t, h1.Hash("foo"), h2.Hash("foo"))
+	})
+
+	t.Run("integers", func(t *testing.T) {
+		h := litecache.NewMaphashHasher[userID]()
+		require.NotNil(t, h)
+
+		assert.Equal(t, h.Hash(42), h.Hash(userID(42)))
+		assert.NotEqual(t, h.Hash(42), h.Hash(43))
+
+		assert.NotNil(t, litecache.NewMaphashHasher[uint8]())
+		assert.NotNil(t, litecache.NewMaphashHasher[int32]())
+	})
+
+	t.Run("other key types are not supported", func(t *testing.T) {
+		assert.Nil(t, litecache.NewMaphashHasher[tenantKey]())
+	})
+}
+
+func TestCache_WithHasher(t *testing.T) {
+	t.Parallel()
+
+	t.Run("maphash hasher", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().
+			WithHasher(litecache.NewMaphashHasher[string]()).
+			WithMaxEntries(1000).
+			WithTinyLFU(true)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 100; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), i)
+		}
+
+		for i := 0; i < 100; i++ {
+			v, found := c.Get(fmt.Sprintf("key:%d", i))
+			assert.True(t, found)
+			assert.Equal(t, i, v)
+		}
+	})
+
+	t.Run("custom hasher", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().WithHasher(constantHasher{})
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("foo", 1)
+		c.Set("bar", 2)
+		assert.Equal(t, 2, c.CountPrecise())
+
+		v, found := c.Get("bar")
+		assert.True(t, found)
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("nil hasher", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		_, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithHasher(nil))
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+	})
+}
@@ -5,30 +5,90 @@ import (
 	"time"
 )
 
-type janitor[T any] struct {
-	ctx      context.Context
-	interval time.Duration
+const (
+	// DefaultJanitorEntriesBudget is the max number of expiration checks per janitor tick
+	DefaultJanitorEntriesBudget = 20_000
+	// DefaultJanitorTimeBudget is the max time a janitor tick may take
+	DefaultJanitorTimeBudget = 25 * time.Millisecond
+)
+
+// janitor is a single goroutine, that cleans expired items of all the shards. Every tick it visits
+// the shards round-robin, giving each of them a share of the entries budget, and keeps going over the shards
+// that still have due items, until the budget is spent or nothing is due. The next tick continues with
+// the shard the previous one stopped at, so no shard starves when the budget is not enough for all of them.
+type janitor[K comparable, V any] struct {
+	ctx        context.Context
+	clock      Clock
+	interval   time.Duration
+	shards     func() []*shard[K, V]
+	budget     int
+	timeBudget time.Duration
+	cursor     int
 }
 
-func newJanitor[T any](ctx context.Context, runEvery time.Duration) *janitor[T] {
-	return &janitor[T]{
-		ctx:      ctx,
-		interval: runEvery,
+func newJanitor[K comparable, V any](
+	ctx context.Context,
+	clock Clock,
+	runEvery time.Duration,
+	budget int,
+	timeBudget time.Duration,
+	shards func() []*shard[K, V],
+) *janitor[K, V] {
+	return &janitor[K, V]{
+		ctx:        ctx,
+		clock:      clock,
+		interval:   runEvery,
+		shards:     shards,
+		budget:     budget,
+		timeBudget: timeBudget,
 	}
 }
 
-func (j *janitor[T]) runOn(s *shard[T], onAfterIteration func(key string, value T)) {
+func (j *janitor[K, V]) run() {
+	// the ticker is created before the goroutine starts, so that it counts from the creation of the cache
+	tick := j.clock.NewTicker(j.interval)
 	go func() {
-		tick := time.NewTicker(j.interval)
-
 		for {
 			select {
 			case <-j.ctx.Done():
 				tick.Stop()
 				return
-			case <-tick.C:
-				s.cleanExpired(onAfterIteration)
+			case <-tick.C():
+				j.sweep()
 			}
 		}
 	}()
 }
+
+// sweep runs one expire cycle and returns the number of deleted items,
+// the time budget limits the real work done, so it is measured with the system time
+func (j *janitor[K, V]) sweep() int {
+	deadline := time.Now().Add(j.timeBudget)
+	// shards change when the cache is resharded
+	shards := j.shards()
+	j.cursor %= len(shards)
+	share := max(j.budget/len(shards), 1)
+	remaining := j.budget
+	deleted := 0
+
+	// done counts consecutive shards that have nothing due, a full round of them ends the cycle
+	for done := 0; done < len(shards) && remaining > 0; {
+		s := shards[j.cursor]
+		j.cursor = (j.cursor + 1) % len(shards)
+
+		checked, evicted, more := s.cleanExpired(min(share, remaining))
+		remaining -= checked
+		deleted += evicted
+		if more {
+			done = 0
+		} else {
+			done++
+		}
+
+		if time.Now().After(deadline) {
+			break
+		}
+	}
+
+	return deleted
+}
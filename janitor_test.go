@@ -0,0 +1,165 @@
+package litecache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denismitr/litecache"
+)
+
+func TestJanitor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("evicts only due keys", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var mu sync.Mutex
+		evicted := make(map[string]int)
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(4).
+			WithTtlChecksInterval(10 * time.Millisecond).
+			WithOnEvict(func(key string, value int) {
+				mu.Lock()
+				defer mu.Unlock()
+				evicted[key] = value
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 1_000; i++ {
+			c.SetTtl(fmt.Sprintf("long:%d", i), i, time.Hour)
+			c.SetTtl(fmt.Sprintf("short:%d", i), i, 20*time.Millisecond)
+			c.Set(fmt.Sprintf("forever:%d", i), i)
+		}
+
+		require.Eventually(t, func() bool {
+			return c.Count() == 2_000
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, 2_000, c.CountPrecise())
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Len(t, evicted, 1_000)
+		for i := 0; i < 1_000; i++ {
+			v, ok := evicted[fmt.Sprintf("short:%d", i)]
+			assert.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+	})
+
+	t.Run("rescheduled keys are evicted at their new expiration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithTtlChecksInterval(10 * time.Millisecond)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.SetTtl("extended", 1, 20*time.Millisecond)
+		c.SetTtl("extended", 1, 200*time.Millisecond)
+		c.SetTtl("shortened", 2, time.Hour)
+		c.SetTtl("shortened", 2, 20*time.Millisecond)
+		c.SetTtl("persisted", 3, 20*time.Millisecond)
+		c.Set("persisted", 3)
+
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, 2, c.CountPrecise())
+
+		{
+			v, found := c.Get("extended")
+			assert.True(t, found)
+			assert.Equal(t, 1, v)
+		}
+
+		require.Eventually(t, func() bool {
+			return c.CountPrecise() == 1
+		}, time.Second, 10*time.Millisecond)
+
+		v, found := c.Get("persisted")
+		assert.True(t, found)
+		assert.Equal(t, 3, v)
+	})
+
+	t.Run("sliding keys are kept while read", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(1).
+			WithTtlChecksInterval(10 * time.Millisecond)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.SetSliding("foo", 1, 50*time.Millisecond)
+		for i := 0; i < 10; i++ {
+			time.Sleep(20 * time.Millisecond)
+			_, found := c.Get("foo")
+			require.True(t, found)
+		}
+		assert.Equal(t, 1, c.CountPrecise())
+
+		require.Eventually(t, func() bool {
+			return c.CountPrecise() == 0
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, 0, c.Count())
+	})
+}
+
+func TestJanitor_Budget(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid budget", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		for _, cfg := range []litecache.Config[int]{
+			litecache.NewDefaultConfig[int]().WithJanitorBudget(0, time.Millisecond),
+			litecache.NewDefaultConfig[int]().WithJanitorBudget(10, 0),
+		} {
+			c, err := litecache.NewWithConfig[int](ctx, cfg)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, litecache.ErrInvalidConfig))
+			require.Nil(t, c)
+		}
+	})
+
+	t.Run("expired keys are cleaned over several ticks", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().
+			WithShards(8).
+			WithTtlChecksInterval(20*time.Millisecond).
+			WithJanitorBudget(100, time.Second)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		const N = 2_000
+		for i := 0; i < N; i++ {
+			c.SetTtl(fmt.Sprintf("key:%d", i), i, time.Millisecond)
+		}
+
+		time.Sleep(30 * time.Millisecond)
+		assert.Greater(t, c.CountPrecise(), N/2)
+
+		require.Eventually(t, func() bool {
+			return c.CountPrecise() == 0
+		}, 5*time.Second, 20*time.Millisecond)
+		assert.Equal(t, 0, c.Count())
+	})
+}
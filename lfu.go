@@ -0,0 +1,104 @@
+package litecache
+
+import "container/heap"
+
+// lfuAgingFactor defines after how many accesses, relative to the shard capacity,
+// all the frequencies are halved, so that keys which used to be popular can be evicted
+const lfuAgingFactor = 10
+
+type lfuEntry[K comparable] struct {
+	key   K
+	freq  uint32
+	index int
+}
+
+// lfuHeap is a min heap of entries ordered by access frequency
+type lfuHeap[K comparable] []*lfuEntry[K]
+
+func (h lfuHeap[K]) Len() int           { return len(h) }
+func (h lfuHeap[K]) Less(i, j int) bool { return h[i].freq < h[j].freq }
+
+func (h lfuHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K]) Push(x any) {
+	e := x.(*lfuEntry[K])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[K]) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+// lfuPolicy keeps decaying access frequencies of the shard keys
+type lfuPolicy[K comparable] struct {
+	entries  lfuHeap[K]
+	index    map[K]*lfuEntry[K]
+	accesses int
+	agingAt  int
+}
+
+// NewLFUPolicy creates a policy that evicts the least frequently used keys.
+// Frequencies are halved periodically, so that keys which used to be popular can be evicted.
+func NewLFUPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	return &lfuPolicy[K]{
+		entries: make(lfuHeap[K], 0, capacity),
+		index:   make(map[K]*lfuEntry[K], capacity),
+		agingAt: capacity * lfuAgingFactor,
+	}
+}
+
+func (p *lfuPolicy[K]) OnAccess(key K) {
+	e, ok := p.index[key]
+	if !ok {
+		return
+	}
+
+	e.freq++
+	heap.Fix(&p.entries, e.index)
+
+	p.accesses++
+	if p.accesses >= p.agingAt {
+		p.age()
+	}
+}
+
+func (p *lfuPolicy[K]) OnInsert(key K) {
+	e := &lfuEntry[K]{key: key, freq: 1}
+	heap.Push(&p.entries, e)
+	p.index[key] = e
+}
+
+func (p *lfuPolicy[K]) OnRemove(key K) {
+	if e, ok := p.index[key]; ok {
+		heap.Remove(&p.entries, e.index)
+		delete(p.index, key)
+	}
+}
+
+func (p *lfuPolicy[K]) Victim() (K, bool) {
+	if len(p.entries) == 0 {
+		var zero K
+		return zero, false
+	}
+	return p.entries[0].key, true
+}
+
+// age halves all the frequencies, the order of the heap is preserved
+// since halving does not change relative order of the entries
+func (p *lfuPolicy[K]) age() {
+	for _, e := range p.entries {
+		e.freq /= 2
+	}
+	p.accesses = 0
+}
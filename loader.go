@@ -0,0 +1,256 @@
+package litecache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// refreshRetryInterval postpones the refresh of a value after a failed reload, when refresh after write is not set
+const refreshRetryInterval = time.Second
+
+var (
+	ErrLoaderPanicked = errors.New("loader panicked")
+	ErrNoLoader       = errors.New("no loader configured")
+	ErrNotFound       = errors.New("not found")
+)
+
+// LookupState tells what the cache holds for a key
+type LookupState uint8
+
+const (
+	// LookupMiss means that there is neither a value nor a cached failure for the key
+	LookupMiss LookupState = iota
+	// LookupHit means that the value for the key is in the cache
+	LookupHit
+	// LookupFailed means that the last load of the key failed and the failure is cached
+	LookupFailed
+)
+
+// Lookup returns the value for a key and what the cache holds for it. When the last load of the key
+// has failed and negative caching is configured, it returns LookupFailed with the error of the loader,
+// until the key can be loaded again.
+func (c *KeyedCache[K, V]) Lookup(key K) (V, LookupState, error) {
+	if v, found := c.Get(key); found {
+		return v, LookupHit, nil
+	}
+
+	if err := c.getShard(key).failed(key); err != nil {
+		return zeroV[V](), LookupFailed, err
+	}
+
+	return zeroV[V](), LookupMiss, nil
+}
+
+// GetOrLoad returns the value for a key, if it exists and has not expired, otherwise it calls the loader
+// and stores the loaded value with the ttl returned by the loader, ttl of 0 or less means no expiration.
+// Concurrent misses of the same key call the loader only once, the other callers wait for its result,
+// unless their context is done first. The loader is called with the context of the caller that started the load.
+// Loader errors are returned to all the waiting callers and nothing is stored, unless negative caching
+// is configured, in which case the error is returned without calling the loader until the negative ttl has passed.
+func (c *KeyedCache[K, V]) GetOrLoad(
+	ctx context.Context,
+	key K,
+	loader func(ctx context.Context) (V, time.Duration, error),
+) (V, error) {
+	return c.getOrLoad(ctx, key, func(ctx context.Context) (V, error) {
+		v, ttl, err := loader(ctx)
+		if err != nil {
+			return zeroV[V](), err
+		}
+
+		c.SetTtl(key, v, ttl)
+		return v, nil
+	})
+}
+
+// Fetch returns the value for a key, if it exists and has not expired, otherwise it loads the value
+// with the loader from the config and stores it with the loader ttl. Concurrent misses are deduplicated
+// the same way as in GetOrLoad. When only the load all func is configured, it is called with the single key
+// and ErrNotFound is returned if the result does not contain the key.
+// ErrNoLoader is returned when the cache has neither.
+func (c *KeyedCache[K, V]) Fetch(ctx context.Context, key K) (V, error) {
+	if !c.loading.configured() {
+		return zeroV[V](), ErrNoLoader
+	}
+
+	return c.getOrLoad(ctx, key, func(ctx context.Context) (V, error) {
+		return c.load(ctx, key)
+	})
+}
+
+// FetchAll returns the values for the given keys, missing keys are loaded with a single call
+// of the load all func from the config, or one by one with Fetch, when only the loader is configured.
+// Keys that the load all func did not return are absent from the result, with negative caching
+// they are not loaded again until the negative ttl has passed. Keys with cached failures of the loader
+// are absent from the result as well, while the rest of the keys are loaded.
+// ErrNoLoader is returned when the cache has neither.
+func (c *KeyedCache[K, V]) FetchAll(ctx context.Context, keys []K) (map[K]V, error) {
+	if !c.loading.configured() {
+		return nil, ErrNoLoader
+	}
+
+	result := make(map[K]V, len(keys))
+	var missing []K
+	for _, key := range keys {
+		if v, found := c.Get(key); found {
+			result[key] = v
+		} else {
+			missing = append(missing, key)
+		}
+	}
+
+	// keys with cached failures are skipped, so that they do not fail the rest of the batch
+	load := missing[:0]
+	for _, key := range missing {
+		if c.getShard(key).failed(key) == nil {
+			load = append(load, key)
+		}
+	}
+
+	if len(load) == 0 {
+		return result, nil
+	}
+
+	if c.loading.loadAll == nil {
+		for _, key := range load {
+			v, err := c.Fetch(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = v
+		}
+		return result, nil
+	}
+
+	loaded, err := c.loading.loadAll(ctx, load)
+	if err != nil {
+		for _, key := range load {
+			c.fail(key, err)
+		}
+		return nil, err
+	}
+
+	for _, key := range load {
+		if v, ok := loaded[key]; ok {
+			c.storeLoaded(key, v)
+			result[key] = v
+		} else {
+			c.fail(key, ErrNotFound)
+		}
+	}
+
+	return result, nil
+}
+
+// loaderConfig holds the loaders from the config and the way the loaded values are stored
+type loaderConfig[K comparable, V any] struct {
+	loader       func(ctx context.Context, key K) (V, error)
+	loadAll      func(ctx context.Context, keys []K) (map[K]V, error)
+	ttl          time.Duration
+	refreshAfter time.Duration
+	staleWindow  time.Duration
+	// negativeTtl is the ttl of cached loader errors, 0 disables negative caching
+	negativeTtl    time.Duration
+	negativeMaxTtl time.Duration
+}
+
+func (l loaderConfig[K, V]) configured() bool {
+	return l.loader != nil || l.loadAll != nil
+}
+
+func (c *KeyedCache[K, V]) getOrLoad(ctx context.Context, key K, load func(ctx context.Context) (V, error)) (V, error) {
+	if v, found := c.Get(key); found {
+		return v, nil
+	}
+
+	if err := c.getShard(key).failed(key); err != nil {
+		return zeroV[V](), err
+	}
+
+	return c.loads.do(ctx, key, func() (V, error) {
+		// the value might have been stored by a load, that has just finished
+		if v, found := c.Get(key); found {
+			return v, nil
+		}
+
+		v, err := load(ctx)
+		if err != nil {
+			c.fail(key, err)
+		}
+		return v, err
+	})
+}
+
+// fail caches the loader error, when negative caching is configured,
+// context errors belong to the caller, so they are not cached
+func (c *KeyedCache[K, V]) fail(key K, err error) {
+	if c.loading.negativeTtl <= 0 || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	maxTtl := max(c.loading.negativeMaxTtl, c.loading.negativeTtl)
+	c.getShard(key).fail(key, err, c.loading.negativeTtl, maxTtl)
+}
+
+// refreshIfDue reloads the key in the background, when its refresh time has come, there is no ongoing load
+// of the key and its last load has not failed recently. A failed refresh is cached as a failure of the loader
+// and postpones the next refresh, so that the reads of the stale value do not call the loader again and again.
+func (c *KeyedCache[K, V]) refreshIfDue(key K, refresh int64) {
+	if !c.loading.configured() || c.clock.Now().UnixNano() < refresh {
+		return
+	}
+
+	if c.getShard(key).failed(key) != nil {
+		return
+	}
+
+	c.loads.start(key, func() (V, error) {
+		v, err := c.load(c.ctx, key)
+		if err != nil {
+			c.fail(key, err)
+
+			retry := c.loading.refreshAfter
+			if retry <= 0 {
+				retry = refreshRetryInterval
+			}
+			c.getShard(key).postponeRefresh(key, c.clock.Now().Add(retry).UnixNano())
+		}
+		return v, err
+	})
+}
+
+// load loads the value of the key with the loaders from the config and stores it
+func (c *KeyedCache[K, V]) load(ctx context.Context, key K) (V, error) {
+	v, err := c.loadOne(ctx, key)
+	if err != nil {
+		return zeroV[V](), err
+	}
+
+	c.storeLoaded(key, v)
+	return v, nil
+}
+
+func (c *KeyedCache[K, V]) storeLoaded(key K, value V) {
+	shard := c.getShard(key)
+	if shard.setLoaded(key, value, c.loading.ttl, c.loading.staleWindow, c.loading.refreshAfter) {
+		c.len.Add(1)
+	}
+}
+
+func (c *KeyedCache[K, V]) loadOne(ctx context.Context, key K) (V, error) {
+	if c.loading.loader != nil {
+		return c.loading.loader(ctx, key)
+	}
+
+	loaded, err := c.loading.loadAll(ctx, []K{key})
+	if err != nil {
+		return zeroV[V](), err
+	}
+
+	v, ok := loaded[key]
+	if !ok {
+		return zeroV[V](), ErrNotFound
+	}
+	return v, nil
+}
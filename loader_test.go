@@ -0,0 +1,671 @@
+package litecache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denismitr/litecache"
+)
+
+func TestCache_GetOrLoad(t *testing.T) {
+	t.Parallel()
+
+	t.Run("concurrent misses call the loader once", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := litecache.New[int](ctx)
+
+		var calls atomic.Int32
+		release := make(chan struct{})
+		loader := func(ctx context.Context) (int, time.Duration, error) {
+			calls.Add(1)
+			<-release
+			return 42, time.Minute, nil
+		}
+
+		const callers = 100
+		var wg sync.WaitGroup
+		results := make([]int, callers)
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				v, err := c.GetOrLoad(ctx, "foo", loader)
+				assert.NoError(t, err)
+				results[i] = v
+			}(i)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), calls.Load())
+		for _, v := range results {
+			assert.Equal(t, 42, v)
+		}
+
+		v, found := c.Get("foo")
+		assert.True(t, found)
+		assert.Equal(t, 42, v)
+		assert.Equal(t, 1, c.Count())
+	})
+
+	t.Run("cached value does not call the loader", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := litecache.New[string](ctx)
+		c.Set("foo", "bar")
+
+		v, err := c.GetOrLoad(ctx, "foo", func(ctx context.Context) (string, time.Duration, error) {
+			t.Fatal("loader should not be called")
+			return "", 0, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "bar", v)
+	})
+
+	t.Run("loaded value expires with the loader ttl", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		c, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithClock(clock))
+		require.NoError(t, err)
+
+		var calls int
+		loader := func(ctx context.Context) (int, time.Duration, error) {
+			calls++
+			return calls, time.Minute, nil
+		}
+
+		for i := 0; i < 3; i++ {
+			v, err := c.GetOrLoad(ctx, "foo", loader)
+			require.NoError(t, err)
+			assert.Equal(t, 1, v)
+		}
+
+		clock.Advance(time.Minute + time.Second)
+
+		v, err := c.GetOrLoad(ctx, "foo", loader)
+		require.NoError(t, err)
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := litecache.New[int](ctx)
+		errUpstream := errors.New("upstream is down")
+
+		_, err := c.GetOrLoad(ctx, "foo", func(ctx context.Context) (int, time.Duration, error) {
+			return 0, 0, errUpstream
+		})
+		require.ErrorIs(t, err, errUpstream)
+		assert.Equal(t, 0, c.Count())
+
+		v, err := c.GetOrLoad(ctx, "foo", func(ctx context.Context) (int, time.Duration, error) {
+			return 7, 0, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 7, v)
+	})
+
+	t.Run("waiter gives up when its context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := litecache.New[int](ctx)
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		go func() {
+			_, _ = c.GetOrLoad(ctx, "foo", func(ctx context.Context) (int, time.Duration, error) {
+				close(started)
+				<-release
+				return 1, 0, nil
+			})
+		}()
+		<-started
+
+		waiterCtx, waiterCancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer waiterCancel()
+
+		_, err := c.GetOrLoad(waiterCtx, "foo", func(ctx context.Context) (int, time.Duration, error) {
+			t.Fatal("loader should not be called twice")
+			return 0, 0, nil
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		close(release)
+	})
+
+	t.Run("loader panic is reported to the waiters", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := litecache.New[int](ctx)
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		go func() {
+			defer func() {
+				assert.NotNil(t, recover())
+			}()
+
+			_, _ = c.GetOrLoad(ctx, "foo", func(ctx context.Context) (int, time.Duration, error) {
+				close(started)
+				<-release
+				panic("boom")
+			})
+		}()
+		<-started
+
+		errCh := make(chan error)
+		go func() {
+			_, err := c.GetOrLoad(ctx, "foo", func(ctx context.Context) (int, time.Duration, error) {
+				return 0, 0, nil
+			})
+			errCh <- err
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		require.ErrorIs(t, <-errCh, litecache.ErrLoaderPanicked)
+	})
+}
+
+func TestCache_Fetch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no loader", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := litecache.New[int](ctx)
+
+		_, err := c.Fetch(ctx, "foo")
+		require.ErrorIs(t, err, litecache.ErrNoLoader)
+
+		_, err = c.FetchAll(ctx, []string{"foo"})
+		require.ErrorIs(t, err, litecache.ErrNoLoader)
+	})
+
+	t.Run("loads misses with the loader", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		var calls atomic.Int32
+		cfg := litecache.NewDefaultConfig[string]().
+			WithClock(clock).
+			WithLoaderTtl(time.Minute).
+			WithLoader(func(ctx context.Context, key string) (string, error) {
+				calls.Add(1)
+				return "value of " + key, nil
+			})
+
+		c, err := litecache.NewWithConfig[string](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			v, err := c.Fetch(ctx, "foo")
+			require.NoError(t, err)
+			assert.Equal(t, "value of foo", v)
+		}
+		assert.Equal(t, int32(1), calls.Load())
+
+		clock.Advance(time.Minute + time.Second)
+		_, found := c.Get("foo")
+		assert.False(t, found)
+
+		_, err = c.Fetch(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+
+		// without load all the keys are fetched one by one
+		c.Set("bar", "cached bar")
+		values, err := c.FetchAll(ctx, []string{"foo", "bar", "baz"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"foo": "value of foo",
+			"bar": "cached bar",
+			"baz": "value of baz",
+		}, values)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("loads all misses in one call", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var batches [][]string
+		cfg := litecache.NewDefaultConfig[int]().
+			WithLoadAll(func(ctx context.Context, keys []string) (map[string]int, error) {
+				batches = append(batches, keys)
+				result := make(map[string]int)
+				for _, k := range keys {
+					if k != "missing" {
+						result[k] = len(k)
+					}
+				}
+				return result, nil
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("a", 100)
+		values, err := c.FetchAll(ctx, []string{"a", "bb", "ccc", "missing"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"a": 100, "bb": 2, "ccc": 3}, values)
+		assert.Equal(t, [][]string{{"bb", "ccc", "missing"}}, batches)
+		assert.Equal(t, 3, c.Count())
+
+		// single key fetch falls back to load all
+		v, err := c.Fetch(ctx, "dddd")
+		require.NoError(t, err)
+		assert.Equal(t, 4, v)
+
+		_, err = c.Fetch(ctx, "missing")
+		require.ErrorIs(t, err, litecache.ErrNotFound)
+		assert.Len(t, batches, 3)
+	})
+
+	t.Run("load all error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errUpstream := errors.New("upstream is down")
+		cfg := litecache.NewDefaultConfig[int]().
+			WithLoadAll(func(ctx context.Context, keys []string) (map[string]int, error) {
+				return nil, errUpstream
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		values, err := c.FetchAll(ctx, []string{"a"})
+		require.ErrorIs(t, err, errUpstream)
+		assert.Nil(t, values)
+	})
+}
+
+func TestCache_RefreshAhead(t *testing.T) {
+	t.Parallel()
+
+	t.Run("refresh after write reloads in the background", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		var version atomic.Int32
+		release := make(chan struct{})
+		cfg := litecache.NewDefaultConfig[int32]().
+			WithClock(clock).
+			WithLoaderTtl(time.Hour).
+			WithRefreshAfterWrite(time.Minute).
+			WithLoader(func(ctx context.Context, key string) (int32, error) {
+				if version.Load() > 0 {
+					<-release
+				}
+				return version.Add(1), nil
+			})
+
+		c, err := litecache.NewWithConfig[int32](ctx, cfg)
+		require.NoError(t, err)
+
+		v, err := c.Fetch(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), v)
+
+		v, _ = c.Get("foo")
+		assert.Equal(t, int32(1), v)
+
+		clock.Advance(time.Minute + time.Second)
+
+		// the current value is returned while the refresh is in flight
+		for i := 0; i < 10; i++ {
+			v, found := c.Get("foo")
+			assert.True(t, found)
+			assert.Equal(t, int32(1), v)
+		}
+
+		close(release)
+		assert.Eventually(t, func() bool {
+			v, _ := c.Get("foo")
+			return v == 2
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, int32(2), version.Load())
+		assert.Equal(t, 1, c.Count())
+	})
+
+	t.Run("stale value is served while revalidating", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		var version atomic.Int32
+		cfg := litecache.NewDefaultConfig[int32]().
+			WithClock(clock).
+			WithLoaderTtl(time.Minute).
+			WithStaleWhileRevalidate(10 * time.Second).
+			WithLoader(func(ctx context.Context, key string) (int32, error) {
+				return version.Add(1), nil
+			})
+
+		c, err := litecache.NewWithConfig[int32](ctx, cfg)
+		require.NoError(t, err)
+
+		values, err := c.FetchAll(ctx, []string{"foo", "bar"})
+		require.NoError(t, err)
+		assert.Len(t, values, 2)
+
+		clock.Advance(time.Minute + time.Second)
+
+		v, found := c.Get("foo")
+		assert.True(t, found)
+		assert.Less(t, v, int32(3))
+
+		assert.Eventually(t, func() bool {
+			v, _ := c.Get("foo")
+			return v == 3
+		}, time.Second, time.Millisecond)
+
+		// the stale window is over without reads of bar
+		clock.Advance(10 * time.Second)
+		_, found = c.Get("bar")
+		assert.False(t, found)
+
+		v, err = c.Fetch(ctx, "bar")
+		require.NoError(t, err)
+		assert.Equal(t, int32(4), v)
+	})
+
+	t.Run("failed refresh is not retried on every read", func(t *testing.T) {
+		for name, negativeTtl := range map[string]time.Duration{"without negative ttl": 0, "with negative ttl": 10 * time.Second} {
+			t.Run(name, func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				clock := litecache.NewFakeClock(time.Now())
+				var calls atomic.Int32
+				cfg := litecache.NewDefaultConfig[int32]().
+					WithClock(clock).
+					WithLoaderTtl(time.Hour).
+					WithRefreshAfterWrite(time.Minute).
+					WithNegativeTtl(negativeTtl, 0).
+					WithLoader(func(ctx context.Context, key string) (int32, error) {
+						if calls.Add(1) > 1 {
+							return 0, errors.New("db is down")
+						}
+						return 1, nil
+					})
+
+				c, err := litecache.NewWithConfig[int32](ctx, cfg)
+				require.NoError(t, err)
+
+				_, err = c.Fetch(ctx, "foo")
+				require.NoError(t, err)
+
+				readAll := func() {
+					for i := 0; i < 200; i++ {
+						v, found := c.Get("foo")
+						assert.True(t, found)
+						assert.Equal(t, int32(1), v)
+					}
+				}
+
+				clock.Advance(time.Minute + time.Second)
+				readAll()
+				assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+
+				// the refresh has failed, the reads keep the stale value without calling the loader
+				readAll()
+				assert.Never(t, func() bool { return calls.Load() > 2 }, 50*time.Millisecond, time.Millisecond)
+
+				clock.Advance(time.Minute + time.Second)
+				readAll()
+				assert.Eventually(t, func() bool { return calls.Load() == 3 }, time.Second, time.Millisecond)
+			})
+		}
+	})
+
+	t.Run("values set directly are not refreshed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		var calls atomic.Int32
+		cfg := litecache.NewDefaultConfig[int]().
+			WithClock(clock).
+			WithRefreshAfterWrite(time.Minute).
+			WithLoader(func(ctx context.Context, key string) (int, error) {
+				calls.Add(1)
+				return 1, nil
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		c.Set("foo", 10)
+		clock.Advance(time.Hour)
+
+		v, found := c.Get("foo")
+		assert.True(t, found)
+		assert.Equal(t, 10, v)
+
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, int32(0), calls.Load())
+	})
+
+	t.Run("negative durations are invalid", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		_, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithRefreshAfterWrite(-time.Second))
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+
+		_, err = litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithStaleWhileRevalidate(-time.Second))
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+	})
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	t.Parallel()
+
+	t.Run("failures are cached with backoff", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		var calls atomic.Int32
+		cfg := litecache.NewDefaultConfig[string]().
+			WithClock(clock).
+			WithNegativeTtl(time.Second, 4*time.Second).
+			WithLoader(func(ctx context.Context, key string) (string, error) {
+				calls.Add(1)
+				return "", litecache.ErrNotFound
+			})
+
+		c, err := litecache.NewWithConfig[string](ctx, cfg)
+		require.NoError(t, err)
+
+		_, state, err := c.Lookup("foo")
+		assert.Equal(t, litecache.LookupMiss, state)
+		assert.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err := c.Fetch(ctx, "foo")
+			require.ErrorIs(t, err, litecache.ErrNotFound)
+		}
+		assert.Equal(t, int32(1), calls.Load())
+
+		_, state, err = c.Lookup("foo")
+		assert.Equal(t, litecache.LookupFailed, state)
+		assert.ErrorIs(t, err, litecache.ErrNotFound)
+
+		_, found := c.Get("foo")
+		assert.False(t, found)
+		assert.Equal(t, 0, c.Count())
+
+		// every consecutive failure doubles the negative ttl up to the max
+		for _, backoff := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+			calls.Store(0)
+			clock.Advance(backoff - time.Millisecond)
+			_, err := c.Fetch(ctx, "foo")
+			require.ErrorIs(t, err, litecache.ErrNotFound)
+			assert.Equal(t, int32(0), calls.Load(), backoff)
+
+			clock.Advance(time.Millisecond)
+			_, err = c.Fetch(ctx, "foo")
+			require.ErrorIs(t, err, litecache.ErrNotFound)
+			assert.Equal(t, int32(1), calls.Load(), backoff)
+		}
+
+		// the backoff is forgotten after a quiet period
+		clock.Advance(time.Minute)
+		calls.Store(0)
+		_, err = c.Fetch(ctx, "foo")
+		require.ErrorIs(t, err, litecache.ErrNotFound)
+		clock.Advance(time.Second)
+		_, err = c.Fetch(ctx, "foo")
+		require.ErrorIs(t, err, litecache.ErrNotFound)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("set value replaces cached failure", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errUpstream := errors.New("upstream is down")
+		cfg := litecache.NewDefaultConfig[int]().WithNegativeTtl(time.Hour, 0)
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		var calls int
+		loader := func(ctx context.Context) (int, time.Duration, error) {
+			calls++
+			return 0, time.Minute, errUpstream
+		}
+
+		_, err = c.GetOrLoad(ctx, "foo", loader)
+		require.ErrorIs(t, err, errUpstream)
+		_, err = c.GetOrLoad(ctx, "foo", loader)
+		require.ErrorIs(t, err, errUpstream)
+		assert.Equal(t, 1, calls)
+
+		c.Set("foo", 10)
+		v, state, err := c.Lookup("foo")
+		assert.Equal(t, litecache.LookupHit, state)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, v)
+
+		c.Remove("foo")
+		_, state, _ = c.Lookup("foo")
+		assert.Equal(t, litecache.LookupMiss, state)
+	})
+
+	t.Run("context errors are not cached", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := litecache.NewDefaultConfig[int]().WithNegativeTtl(time.Hour, 0)
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		_, err = c.GetOrLoad(ctx, "foo", func(ctx context.Context) (int, time.Duration, error) {
+			return 0, 0, context.DeadlineExceeded
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		_, state, _ := c.Lookup("foo")
+		assert.Equal(t, litecache.LookupMiss, state)
+	})
+
+	t.Run("keys missing from load all are not loaded again", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var batches [][]string
+		cfg := litecache.NewDefaultConfig[int]().
+			WithNegativeTtl(time.Hour, 0).
+			WithLoadAll(func(ctx context.Context, keys []string) (map[string]int, error) {
+				batches = append(batches, append([]string(nil), keys...))
+				return map[string]int{"a": 1}, nil
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		values, err := c.FetchAll(ctx, []string{"a", "missing"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"a": 1}, values)
+
+		values, err = c.FetchAll(ctx, []string{"a", "missing"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"a": 1}, values)
+
+		_, err = c.Fetch(ctx, "missing")
+		require.ErrorIs(t, err, litecache.ErrNotFound)
+		assert.Equal(t, [][]string{{"a", "missing"}}, batches)
+	})
+
+	t.Run("cached failure of one key does not fail the batch", func(t *testing.T) {
+		errDown := errors.New("shard is down")
+		loadAll := func(ctx context.Context, keys []string) (map[string]int, error) {
+			if len(keys) == 1 && keys[0] == "broken" {
+				return nil, errDown
+			}
+
+			values := make(map[string]int, len(keys))
+			for _, key := range keys {
+				values[key] = len(key)
+			}
+			return values, nil
+		}
+
+		for name, cfg := range map[string]litecache.Config[int]{
+			"load all": litecache.NewDefaultConfig[int]().WithLoadAll(loadAll),
+			"loader": litecache.NewDefaultConfig[int]().WithLoader(func(ctx context.Context, key string) (int, error) {
+				values, err := loadAll(ctx, []string{key})
+				return values[key], err
+			}),
+		} {
+			t.Run(name, func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				c, err := litecache.NewWithConfig[int](ctx, cfg.WithNegativeTtl(time.Hour, 0))
+				require.NoError(t, err)
+
+				_, err = c.Fetch(ctx, "broken")
+				require.ErrorIs(t, err, errDown)
+
+				values, err := c.FetchAll(ctx, []string{"a", "broken", "abc"})
+				require.NoError(t, err)
+				assert.Equal(t, map[string]int{"a": 1, "abc": 3}, values)
+			})
+		}
+	})
+
+	t.Run("invalid negative ttl", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		_, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithNegativeTtl(-time.Second, 0))
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+
+		_, err = litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithNegativeTtl(time.Minute, time.Second))
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+	})
+}
@@ -0,0 +1,42 @@
+package litecache
+
+import "container/list"
+
+type lruPolicy[K comparable] struct {
+	recency *list.List
+	elems   map[K]*list.Element
+}
+
+// NewLRUPolicy creates a policy that evicts the least recently used keys
+func NewLRUPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	return &lruPolicy[K]{
+		recency: list.New(),
+		elems:   make(map[K]*list.Element, capacity),
+	}
+}
+
+func (p *lruPolicy[K]) OnAccess(key K) {
+	if elem, ok := p.elems[key]; ok {
+		p.recency.MoveToFront(elem)
+	}
+}
+
+func (p *lruPolicy[K]) OnInsert(key K) {
+	p.elems[key] = p.recency.PushFront(key)
+}
+
+func (p *lruPolicy[K]) OnRemove(key K) {
+	if elem, ok := p.elems[key]; ok {
+		p.recency.Remove(elem)
+		delete(p.elems, key)
+	}
+}
+
+func (p *lruPolicy[K]) Victim() (K, bool) {
+	oldest := p.recency.Back()
+	if oldest == nil {
+		var zero K
+		return zero, false
+	}
+	return oldest.Value.(K), true
+}
@@ -0,0 +1,116 @@
+package litecache
+
+import "sync/atomic"
+
+// readViewMinMisses keeps small shards from rebuilding the read view on every miss
+const readViewMinMisses = 8
+
+// readView is an immutable index of the shard items, that is read without locking.
+// The values of the keys, that are in the view, are swapped atomically by the writers,
+// new keys are added to the view, when it is rebuilt after enough reads missed it.
+type readView[K comparable, V any] struct {
+	entries map[K]*readEntry[V]
+	// complete is true while every key of the shard is in the view, so the keys missing from it are absent
+	complete atomic.Bool
+	misses   atomic.Int64
+}
+
+type readEntry[V any] struct {
+	// p is nil when the key was deleted
+	p atomic.Pointer[item[V]]
+	// initial is the item at the time the entry was created, p points to it until the item changes,
+	// which saves reads of unchanged items a cache miss
+	initial item[V]
+}
+
+// getLockFree reads the item from the read view without locking,
+// ok is false when the view can not tell and the item has to be read under the lock
+func (s *shard[K, V]) getLockFree(key K) (itm item[V], found bool, ok bool) {
+	v := s.view.Load()
+	if v == nil {
+		return itm, false, false
+	}
+
+	e, inView := v.entries[key]
+	if !inView {
+		if v.complete.Load() {
+			return itm, false, true
+		}
+
+		s.missed(v)
+		return itm, false, false
+	}
+
+	p := e.p.Load()
+	switch {
+	case p == nil:
+		return itm, false, true
+	case p.idle > 0:
+		// sliding expiration has to be pushed forward under the write lock
+		return itm, false, false
+	case p.exp > 0 && s.clock.Now().UnixNano() > p.exp:
+		return *p, false, true
+	default:
+		return *p, true, true
+	}
+}
+
+// missed counts the reads, that missed the view, once they outnumber a quarter of the view,
+// the view is rebuilt, so the cost of rebuilding is spread over the misses
+func (s *shard[K, V]) missed(v *readView[K, V]) {
+	if int(v.misses.Add(1)) < len(v.entries)/4+readViewMinMisses {
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.view.Load() == v && s.forward == nil {
+		s.rebuildView()
+	}
+}
+
+// rebuildView publishes a view of all the shard items, the entries of the keys,
+// that are already in the view, are reused, it is called under the write lock
+func (s *shard[K, V]) rebuildView() {
+	var prev map[K]*readEntry[V]
+	if v := s.view.Load(); v != nil {
+		prev = v.entries
+	}
+
+	v := &readView[K, V]{entries: make(map[K]*readEntry[V], len(s.items))}
+	for k, itm := range s.items {
+		e, ok := prev[k]
+		if !ok || e.p.Load() == nil {
+			e = &readEntry[V]{initial: itm}
+			e.p.Store(&e.initial)
+		}
+		v.entries[k] = e
+	}
+
+	v.complete.Store(true)
+	s.view.Store(v)
+}
+
+// publish makes the stored item visible to the lock-free reads, it is called under the write lock
+func (s *shard[K, V]) publish(key K, itm item[V]) {
+	v := s.view.Load()
+	if v == nil {
+		return
+	}
+
+	if e, ok := v.entries[key]; ok {
+		e.p.Store(&itm)
+		return
+	}
+
+	v.complete.Store(false)
+}
+
+// unpublish hides the deleted item from the lock-free reads, it is called under the write lock
+func (s *shard[K, V]) unpublish(key K) {
+	if v := s.view.Load(); v != nil {
+		if e, ok := v.entries[key]; ok {
+			e.p.Store(nil)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package litecache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denismitr/litecache"
+)
+
+func TestCache_LockFreeReads(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reads see every write", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		cfg := litecache.NewDefaultConfig[int]().
+			WithClock(clock).
+			WithShards(4).
+			WithLockFreeReads(true)
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		_, found := c.Get("foo")
+		assert.False(t, found)
+
+		// new keys are visible before the read index is rebuilt
+		c.Set("foo", 1)
+		v, found := c.Get("foo")
+		assert.True(t, found)
+		assert.Equal(t, 1, v)
+
+		for i := 0; i < 1000; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), i)
+			v, found := c.Get(fmt.Sprintf("key:%d", i))
+			require.True(t, found)
+			require.Equal(t, i, v)
+		}
+
+		for i := 0; i < 1000; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), i*2)
+		}
+
+		for i := 0; i < 1000; i++ {
+			v, found := c.Get(fmt.Sprintf("key:%d", i))
+			require.True(t, found)
+			require.Equal(t, i*2, v)
+		}
+
+		assert.True(t, c.Transform("foo", func(v int) int { return v + 10 }))
+		v, _ = c.Get("foo")
+		assert.Equal(t, 11, v)
+
+		assert.True(t, c.Remove("foo"))
+		_, found = c.Get("foo")
+		assert.False(t, found)
+
+		c.Set("foo", 3)
+		v, found = c.Get("foo")
+		assert.True(t, found)
+		assert.Equal(t, 3, v)
+
+		c.SetTtl("ttl", 1, time.Minute)
+		c.SetSliding("sliding", 2, time.Minute)
+		clock.Advance(50 * time.Second)
+		_, found = c.Get("sliding")
+		assert.True(t, found)
+
+		clock.Advance(20 * time.Second)
+		_, found = c.Get("ttl")
+		assert.False(t, found)
+		_, found = c.Get("sliding")
+		assert.True(t, found)
+
+		require.NoError(t, c.Reshard(16))
+		for i := 0; i < 1000; i++ {
+			v, found := c.Get(fmt.Sprintf("key:%d", i))
+			require.True(t, found)
+			require.Equal(t, i*2, v)
+		}
+	})
+
+	t.Run("concurrent reads and writes", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithLockFreeReads(true))
+		require.NoError(t, err)
+
+		const keys = 1000
+		for i := 0; i < keys; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), 0)
+		}
+
+		var stop atomic.Bool
+		var wg sync.WaitGroup
+		for r := 0; r < 4; r++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// values only grow, so a read must never see an older value than the previous read
+				last := make([]int, keys)
+				for i := 0; !stop.Load(); i = (i + 1) % keys {
+					v, found := c.Get(fmt.Sprintf("key:%d", i))
+					assert.True(t, found)
+					assert.GreaterOrEqual(t, v, last[i])
+					last[i] = v
+				}
+			}()
+		}
+
+		for round := 1; round <= 20; round++ {
+			for i := 0; i < keys; i++ {
+				c.Set(fmt.Sprintf("key:%d", i), round)
+				c.Set(fmt.Sprintf("new:%d:%d", round, i), round)
+			}
+		}
+
+		stop.Store(true)
+		wg.Wait()
+
+		for i := 0; i < keys; i++ {
+			v, found := c.Get(fmt.Sprintf("key:%d", i))
+			assert.True(t, found)
+			assert.Equal(t, 20, v)
+		}
+		assert.Equal(t, keys*21, c.Count())
+	})
+
+	t.Run("bounded caches are not supported", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		_, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithLockFreeReads(true).WithMaxEntries(10))
+		require.ErrorIs(t, err, litecache.ErrInvalidConfig)
+	})
+}
@@ -0,0 +1,85 @@
+package litecache
+
+import "fmt"
+
+// reshardBatch is the max number of entries moved to the new shards under a single lock of an old shard
+const reshardBatch = 256
+
+// layout is the set of shards the keys are spread over, while resharding
+// the entries move from the old shards to the new ones
+type layout[K comparable, V any] struct {
+	shards []*shard[K, V]
+	// old are the shards being drained, nil when there is no resharding in progress
+	old []*shard[K, V]
+}
+
+// Reshard changes the number of shards without stopping the cache. The entries move to the new shards
+// in small batches, in the meantime every operation moves its key to the new shards first,
+// so the reads find the key, whether it has already moved or not. Reshard returns once all the entries
+// have moved, concurrent calls wait for each other. Max entries and max cost are split between the new shards,
+// so a bounded cache may evict entries, when it gets fewer shards, and can not get more shards than its bounds.
+// Cached loader failures are not moved.
+func (c *KeyedCache[K, V]) Reshard(n int) error {
+	if n < 1 {
+		return fmt.Errorf("%w: shards should be at least 1", ErrInvalidConfig)
+	}
+
+	if err := c.cfg.validateBounds(n); err != nil {
+		return err
+	}
+
+	c.reshardMux.Lock()
+	defer c.reshardMux.Unlock()
+
+	current := c.layout.Load()
+	if len(current.shards) == n {
+		return nil
+	}
+
+	next := &layout[K, V]{shards: c.newShards(n), old: current.shards}
+
+	// old shards start forwarding the keys before the new layout is published,
+	// so operations, that still hold the current layout, do not write behind the drain
+	for _, s := range next.old {
+		s.retire(func(key K) *shard[K, V] {
+			return c.evacuate(next, key)
+		})
+	}
+	c.layout.Store(next)
+
+	route := func(key K) *shard[K, V] {
+		return next.shards[shardIndex(c.hasher.Hash(key), n)]
+	}
+
+	for _, s := range next.old {
+		for s.drain(reshardBatch, route) {
+		}
+	}
+
+	c.layout.Store(&layout[K, V]{shards: next.shards})
+	return nil
+}
+
+// evacuate moves the key from its old shard to the new one and returns the new shard of the key
+func (c *KeyedCache[K, V]) evacuate(l *layout[K, V], key K) *shard[K, V] {
+	hk := c.hasher.Hash(key)
+	to := l.shards[shardIndex(hk, len(l.shards))]
+	l.old[shardIndex(hk, len(l.old))].move(key, to)
+	return to
+}
+
+func (c *KeyedCache[K, V]) currentShards() []*shard[K, V] {
+	return c.layout.Load().shards
+}
+
+// allShards returns the current shards and the old ones, that are being drained
+func (c *KeyedCache[K, V]) allShards() []*shard[K, V] {
+	l := c.layout.Load()
+	if l.old == nil {
+		return l.shards
+	}
+
+	shards := make([]*shard[K, V], 0, len(l.old)+len(l.shards))
+	shards = append(shards, l.old...)
+	return append(shards, l.shards...)
+}
@@ -0,0 +1,139 @@
+package litecache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denismitr/litecache"
+)
+
+// TestCache_Reshard does not run in parallel, since its busy readers and writers
+// would starve the timing sensitive tests
+func TestCache_Reshard(t *testing.T) {
+	t.Run("entries move to the new shards", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := litecache.New[int](ctx)
+		const entries = 10_000
+		for i := 0; i < entries; i++ {
+			c.Set(fmt.Sprintf("key:%d", i), i)
+		}
+
+		for _, shards := range []int{64, 3, 1, 50} {
+			require.NoError(t, c.Reshard(shards))
+
+			stats := c.ShardStats()
+			require.Len(t, stats, shards)
+			total := 0
+			for _, s := range stats {
+				total += s.Entries
+			}
+			assert.Equal(t, entries, total)
+			assert.Equal(t, entries, c.Count())
+			assert.Equal(t, entries, c.CountPrecise())
+
+			for i := 0; i < entries; i++ {
+				v, found := c.Get(fmt.Sprintf("key:%d", i))
+				require.True(t, found)
+				require.Equal(t, i, v)
+			}
+		}
+
+		require.ErrorIs(t, c.Reshard(0), litecache.ErrInvalidConfig)
+	})
+
+	t.Run("reads and writes during resharding", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c, err := litecache.NewWithConfig[int](ctx, litecache.NewDefaultConfig[int]().WithShards(4))
+		require.NoError(t, err)
+
+		const entries = 50_000
+		for i := 0; i < entries; i++ {
+			c.Set(fmt.Sprintf("stable:%d", i), i)
+		}
+
+		var stop atomic.Bool
+		var misses atomic.Int32
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(2)
+			go func(w int) {
+				defer wg.Done()
+				for i := w; !stop.Load(); i = (i + 4) % entries {
+					if v, found := c.Get(fmt.Sprintf("stable:%d", i)); !found || v != i {
+						misses.Add(1)
+					}
+				}
+			}(w)
+
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 100 || !stop.Load(); i++ {
+					c.Set(fmt.Sprintf("hot:%d:%d", w, i%100), i)
+					c.Transform(fmt.Sprintf("stable:%d", w), func(v int) int { return v })
+				}
+			}(w)
+		}
+
+		for _, shards := range []int{64, 7, 128} {
+			require.NoError(t, c.Reshard(shards))
+		}
+
+		stop.Store(true)
+		wg.Wait()
+
+		assert.Equal(t, int32(0), misses.Load())
+		assert.Equal(t, entries+4*100, c.Count())
+		assert.Equal(t, c.Count(), c.CountPrecise())
+	})
+
+	t.Run("expiration and capacity survive resharding", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		var evicted atomic.Int32
+		cfg := litecache.NewDefaultConfig[int]().
+			WithClock(clock).
+			WithShards(2).
+			WithMaxEntries(100).
+			WithOnEvict(func(key string, value int) {
+				evicted.Add(1)
+			})
+
+		c, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 50; i++ {
+			c.SetTtl(fmt.Sprintf("ttl:%d", i), i, time.Minute)
+			c.Set(fmt.Sprintf("key:%d", i), i)
+		}
+		assert.Equal(t, 100, c.Count())
+
+		require.NoError(t, c.Reshard(8))
+		assert.LessOrEqual(t, c.Count(), 100)
+		assert.Equal(t, c.Count(), c.CountPrecise())
+		assert.Equal(t, int32(100-c.Count()), evicted.Load())
+
+		clock.Advance(time.Minute + time.Second)
+		assert.Eventually(t, func() bool {
+			return c.CountPrecise() <= 50
+		}, time.Second, 5*time.Millisecond)
+
+		for i := 0; i < 50; i++ {
+			_, found := c.Get(fmt.Sprintf("ttl:%d", i))
+			assert.False(t, found)
+		}
+		assert.Equal(t, c.Count(), c.CountPrecise())
+	})
+}
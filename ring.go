@@ -0,0 +1,184 @@
+package litecache
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// entryHeaderSize is the size of the entry header in the ring: expiration, key hash, key length and value length
+const entryHeaderSize = 8 + 8 + 2 + 4
+
+// ring is a fixed size circular log of entries, that are overwritten oldest first, when it is full.
+// Positions grow monotonically, the offset of a position in the buffer is the position modulo the buffer size,
+// so an entry may wrap around the end of the buffer.
+type ring struct {
+	buf []byte
+	// head is the position of the oldest entry
+	head uint64
+	// tail is the position of the next entry
+	tail uint64
+}
+
+func (r *ring) size() uint64 {
+	return uint64(len(r.buf))
+}
+
+func (r *ring) free() uint64 {
+	return r.size() - (r.tail - r.head)
+}
+
+func (r *ring) writeAt(pos uint64, b []byte) {
+	n := copy(r.buf[pos%r.size():], b)
+	copy(r.buf, b[n:])
+}
+
+func (r *ring) writeStringAt(pos uint64, s string) {
+	n := copy(r.buf[pos%r.size():], s)
+	copy(r.buf, s[n:])
+}
+
+func (r *ring) readAt(pos uint64, b []byte) {
+	n := copy(b, r.buf[pos%r.size():])
+	copy(b[n:], r.buf)
+}
+
+// equalAt compares the bytes at the position with the string without copying them
+func (r *ring) equalAt(pos uint64, s string) bool {
+	off := pos % r.size()
+	first := min(uint64(len(s)), r.size()-off)
+	return string(r.buf[off:off+first]) == s[:first] && string(r.buf[:uint64(len(s))-first]) == s[first:]
+}
+
+type entryHeader struct {
+	exp    int64
+	hash   uint64
+	keyLen uint16
+	valLen uint32
+}
+
+func (h entryHeader) size() uint64 {
+	return entryHeaderSize + uint64(h.keyLen) + uint64(h.valLen)
+}
+
+func (r *ring) readHeader(pos uint64) entryHeader {
+	var b [entryHeaderSize]byte
+	r.readAt(pos, b[:])
+	return entryHeader{
+		exp:    int64(binary.LittleEndian.Uint64(b[0:])),
+		hash:   binary.LittleEndian.Uint64(b[8:]),
+		keyLen: binary.LittleEndian.Uint16(b[16:]),
+		valLen: binary.LittleEndian.Uint32(b[18:]),
+	}
+}
+
+func (r *ring) writeHeader(pos uint64, h entryHeader) {
+	var b [entryHeaderSize]byte
+	binary.LittleEndian.PutUint64(b[0:], uint64(h.exp))
+	binary.LittleEndian.PutUint64(b[8:], h.hash)
+	binary.LittleEndian.PutUint16(b[16:], h.keyLen)
+	binary.LittleEndian.PutUint32(b[18:], h.valLen)
+	r.writeAt(pos, b[:])
+}
+
+// bytesShard keeps the entries in a ring and indexes them by the key hash, neither of them holds pointers,
+// so the garbage collector does not scan the entries. Updated and removed entries stay in the ring,
+// until it wraps around and overwrites them.
+type bytesShard struct {
+	mux   sync.RWMutex
+	index map[uint64]uint32
+	ring  ring
+	clock Clock
+}
+
+func newBytesShard(size int, clock Clock) *bytesShard {
+	return &bytesShard{
+		index: make(map[uint64]uint32),
+		ring:  ring{buf: make([]byte, size)},
+		clock: clock,
+	}
+}
+
+// set appends the entry to the ring, overwriting the oldest entries if needed,
+// it reports false if the entry is larger than the ring
+func (s *bytesShard) set(key string, hash uint64, value []byte, exp int64) bool {
+	if entryHeaderSize+uint64(len(key))+uint64(len(value)) > s.ring.size() {
+		return false
+	}
+
+	h := entryHeader{exp: exp, hash: hash, keyLen: uint16(len(key)), valLen: uint32(len(value))}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for s.ring.free() < h.size() {
+		s.evictOldest()
+	}
+
+	pos := s.ring.tail
+	s.ring.writeHeader(pos, h)
+	s.ring.writeStringAt(pos+entryHeaderSize, key)
+	s.ring.writeAt(pos+entryHeaderSize+uint64(h.keyLen), value)
+	s.ring.tail += h.size()
+	s.index[hash] = uint32(pos % s.ring.size())
+	return true
+}
+
+// evictOldest drops the oldest entry of the ring, its index entry is deleted
+// only if it still points to it and not to a newer entry of the key
+func (s *bytesShard) evictOldest() {
+	h := s.ring.readHeader(s.ring.head)
+	if off, ok := s.index[h.hash]; ok && uint64(off) == s.ring.head%s.ring.size() {
+		delete(s.index, h.hash)
+	}
+	s.ring.head += h.size()
+}
+
+func (s *bytesShard) get(key string, hash uint64) ([]byte, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	pos, h, ok := s.lookup(key, hash)
+	if !ok || (h.exp > 0 && s.clock.Now().UnixNano() > h.exp) {
+		return nil, false
+	}
+
+	value := make([]byte, h.valLen)
+	s.ring.readAt(pos+entryHeaderSize+uint64(h.keyLen), value)
+	return value, true
+}
+
+func (s *bytesShard) remove(key string, hash uint64) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	_, h, ok := s.lookup(key, hash)
+	if !ok {
+		return false
+	}
+
+	delete(s.index, hash)
+	return h.exp <= 0 || s.clock.Now().UnixNano() <= h.exp
+}
+
+// lookup finds the entry of the key, keys with the same hash replace each other in the index,
+// so the key of the entry is compared with the given one
+func (s *bytesShard) lookup(key string, hash uint64) (uint64, entryHeader, bool) {
+	off, ok := s.index[hash]
+	if !ok {
+		return 0, entryHeader{}, false
+	}
+
+	pos := uint64(off)
+	h := s.ring.readHeader(pos)
+	if int(h.keyLen) != len(key) || !s.ring.equalAt(pos+entryHeaderSize, key) {
+		return 0, entryHeader{}, false
+	}
+
+	return pos, h, true
+}
+
+func (s *bytesShard) count() int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return len(s.index)
+}
@@ -1,175 +1,730 @@
 package litecache
 
 import (
+	"container/heap"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type item[T any] struct {
-	value T
+// autoCost tells the shard to compute the cost of the item with the cost func
+const autoCost int64 = -1
+
+type item[V any] struct {
+	value V
 	exp   int64
+	cost  int64
+	// idle is the sliding expiration in nanoseconds, every read pushes exp forward by idle
+	idle int64
+	// sched is the time of the pending expiration check in the expiry heap, 0 if there is none
+	sched int64
+	// refresh is the time after which a read of a loaded item triggers a reload, 0 if there is none
+	refresh int64
+}
+
+// failure is a cached error of the loader
+type failure struct {
+	err      error
+	attempts int
+	// retry is the time after which the key can be loaded again
+	retry int64
+	// exp is the time after which the failure is forgotten, it outlives retry to back off repeated failures
+	exp int64
+}
+
+type shardConfig[K comparable, V any] struct {
+	// capacity is the max number of items, 0 means unlimited
+	capacity int
+	// maxCost is the max total cost of items, 0 means unlimited
+	maxCost  int64
+	costFunc func(key K, value V) int64
+	// policy is required when either capacity or max cost is set
+	policy EvictionPolicy[K]
+	// admission is an optional filter, that decides whether a new key is worth evicting the victim
+	admission *tinyLFU[K]
+	onEvict   func(key K, value V)
+	clock     Clock
+	// lockFree makes reads go through the read view without locking, it requires an unbounded shard
+	lockFree bool
+}
+
+type shard[K comparable, V any] struct {
+	shardConfig[K, V]
+	mux      sync.RWMutex
+	items    map[K]item[V]
+	cost     int64
+	readable ReadAccessPolicy[K]
+	expiries expiryHeap[K]
+	// failures are kept apart from the items, they are neither counted nor evicted
+	failures        map[K]failure
+	failureExpiries expiryHeap[K]
+	// forward returns the shard of the key in the new layout, once the shard was retired by resharding
+	forward func(key K) *shard[K, V]
+	// view is the index of the items for lock-free reads, nil unless the shard is lock-free
+	view atomic.Pointer[readView[K, V]]
 }
 
-type shard[T any] struct {
-	mux   sync.RWMutex
-	items map[string]item[T]
+// newShard creates a shard, when it is bounded by capacity or max cost
+// items chosen by the eviction policy get evicted to make room for new ones.
+func newShard[K comparable, V any](cfg shardConfig[K, V]) *shard[K, V] {
+	s := &shard[K, V]{
+		shardConfig: cfg,
+		items:       make(map[K]item[V]),
+	}
+
+	s.readable, _ = cfg.policy.(ReadAccessPolicy[K])
+	if cfg.lockFree {
+		s.rebuildView()
+	}
+
+	return s
 }
 
-func newShard[T any]() *shard[T] {
-	return &shard[T]{
-		items: make(map[string]item[T]),
+// lock write locks the shard, that owns the key, a retired shard forwards the key to its new shard
+func (s *shard[K, V]) lock(key K) *shard[K, V] {
+	for {
+		s.mux.Lock()
+		forward := s.forward
+		if forward == nil {
+			return s
+		}
+		s.mux.Unlock()
+		s = forward(key)
 	}
 }
 
-func (s *shard[T]) get(key string) (item[T], bool) {
-	s.mux.RLock()
-	defer s.mux.RUnlock()
+// rlock read locks the shard, that owns the key, a retired shard forwards the key to its new shard
+func (s *shard[K, V]) rlock(key K) *shard[K, V] {
+	for {
+		s.mux.RLock()
+		forward := s.forward
+		if forward == nil {
+			return s
+		}
+		s.mux.RUnlock()
+		s = forward(key)
+	}
+}
+
+func (s *shard[K, V]) get(key K) (item[V], bool) {
+	if s.lockFree {
+		if itm, found, ok := s.getLockFree(key); ok {
+			return itm, found
+		}
+	}
+
+	if s.policy != nil && s.readable == nil {
+		return s.getExclusive(key)
+	}
+
+	s = s.rlock(key)
 	item, ok := s.items[key]
-	if ok && item.exp > 0 && time.Now().UnixNano() > item.exp {
+	if ok && item.idle > 0 {
+		// sliding expiration has to be pushed forward under the write lock
+		s.mux.RUnlock()
+		return s.getExclusive(key)
+	}
+	defer s.mux.RUnlock()
+
+	if s.admission != nil {
+		s.admission.record(key)
+	}
+
+	if ok && item.exp > 0 && s.clock.Now().UnixNano() > item.exp {
 		return item, false
 	}
 
+	if ok && s.readable != nil {
+		s.readable.OnReadAccess(key)
+	}
+
 	return item, ok
 }
 
-func (s *shard[T]) iterate(fn func(k string, v T)) {
+// getExclusive is used when a read modifies the shard, either because the policy
+// can register reads only under the write lock or because the item has sliding expiration
+func (s *shard[K, V]) getExclusive(key K) (item[V], bool) {
+	s = s.lock(key)
+	defer s.mux.Unlock()
+	if s.admission != nil {
+		s.admission.record(key)
+	}
+
+	now := s.clock.Now().UnixNano()
+	item, ok := s.items[key]
+	if ok && item.exp > 0 && now > item.exp {
+		return item, false
+	}
+
+	if !ok {
+		return item, false
+	}
+
+	if s.policy != nil {
+		s.policy.OnAccess(key)
+	}
+
+	if item.idle > 0 {
+		item.exp = now + item.idle
+		s.items[key] = item
+		s.publish(key, item)
+	}
+
+	return item, true
+}
+
+func (s *shard[K, V]) iterate(fn func(k K, v V)) {
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
 	for k, itm := range s.items {
-		if itm.exp > 0 && time.Now().UnixNano() > itm.exp {
+		if itm.exp > 0 && s.clock.Now().UnixNano() > itm.exp {
 			continue
 		}
 		fn(k, itm.value)
 	}
 }
 
-func (s *shard[T]) set(key string, value T, ttl time.Duration) bool {
-	s.mux.Lock()
+func (s *shard[K, V]) set(key K, value V, ttl time.Duration, cost int64) bool {
+	s = s.lock(key)
 	defer s.mux.Unlock()
 
-	var added bool
-	if _, exists := s.items[key]; !exists {
-		added = true
+	exp := int64(-1)
+	if ttl > 0 {
+		exp = s.clock.Now().UnixNano() + ttl.Nanoseconds()
 	}
 
-	exp := int64(-1)
+	added, _ := s.store(key, item[V]{value: value, exp: exp, cost: cost})
+	return added
+}
+
+// setSliding sets the item, which expiration is pushed forward by idle on every read
+func (s *shard[K, V]) setSliding(key K, value V, idle time.Duration) bool {
+	s = s.lock(key)
+	defer s.mux.Unlock()
+
+	itm := item[V]{value: value, exp: int64(NoExpiration), cost: autoCost}
+	if idle > 0 {
+		itm.idle = idle.Nanoseconds()
+		itm.exp = s.clock.Now().UnixNano() + itm.idle
+	}
+
+	added, _ := s.store(key, itm)
+	return added
+}
+
+// setLoaded sets the item loaded by the cache loader, the item stays in the shard for the stale window
+// after its ttl and becomes due for refresh when it is stale or older than refresh after
+func (s *shard[K, V]) setLoaded(key K, value V, ttl, staleWindow, refreshAfter time.Duration) bool {
+	s = s.lock(key)
+	defer s.mux.Unlock()
+
+	now := s.clock.Now().UnixNano()
+	itm := item[V]{value: value, exp: int64(NoExpiration), cost: autoCost}
 	if ttl > 0 {
-		exp = time.Now().UnixNano() + ttl.Nanoseconds()
+		itm.exp = now + ttl.Nanoseconds() + staleWindow.Nanoseconds()
+		if staleWindow > 0 {
+			itm.refresh = now + ttl.Nanoseconds()
+		}
+	}
+
+	if refreshAfter > 0 && (itm.refresh == 0 || now+refreshAfter.Nanoseconds() < itm.refresh) {
+		itm.refresh = now + refreshAfter.Nanoseconds()
 	}
 
-	s.items[key] = item[T]{value: value, exp: exp}
+	added, _ := s.store(key, itm)
 	return added
 }
 
-func (s *shard[T]) transform(key string, effector func(value T) T) bool {
-	s.mux.Lock()
+func (s *shard[K, V]) transform(key K, effector func(value V) V) bool {
+	s = s.lock(key)
 	defer s.mux.Unlock()
 
 	itm, exists := s.items[key]
 	// if exists and expired return false
-	if !exists || (itm.exp > 0 && itm.exp < time.Now().UnixNano()) {
+	if !exists || (itm.exp > 0 && itm.exp < s.clock.Now().UnixNano()) {
 		return false
 	}
 
-	modified := effector(itm.value)
-	s.items[key] = item[T]{value: modified, exp: itm.exp}
-	return true
+	itm.value = effector(itm.value)
+	itm.cost = autoCost
+	_, stored := s.store(key, itm)
+	return stored
 }
 
-func (s *shard[T]) setNX(key string, value T, ttl time.Duration) bool {
-	s.mux.Lock()
+// setNX reports whether the item was set and whether the key is new to the shard,
+// since an expired item might still be in the shard waiting for the janitor
+func (s *shard[K, V]) setNX(key K, value V, ttl time.Duration) (stored bool, added bool) {
+	s = s.lock(key)
 	defer s.mux.Unlock()
 
 	// if exists and not expired return false
 	if item, exists := s.items[key]; exists {
-		if item.exp <= 0 || item.exp > time.Now().UnixNano() {
-			return false
+		if item.exp <= 0 || item.exp > s.clock.Now().UnixNano() {
+			return false, false
 		}
 	}
 
 	exp := int64(NoExpiration)
 	if ttl > 0 {
-		exp = time.Now().UnixNano() + ttl.Nanoseconds()
+		exp = s.clock.Now().UnixNano() + ttl.Nanoseconds()
 	}
 
-	s.items[key] = item[T]{value: value, exp: exp}
-	return true
+	added, stored = s.store(key, item[V]{value: value, exp: exp, cost: autoCost})
+	return stored, added
 }
 
-func (s *shard[T]) setEX(key string, value T, ttl time.Duration) bool {
-	s.mux.Lock()
+func (s *shard[K, V]) setEX(key K, value V, ttl time.Duration) bool {
+	s = s.lock(key)
 	defer s.mux.Unlock()
 
 	itm, exists := s.items[key]
 	// if exists and expired return false
-	if !exists || (itm.exp > 0 && itm.exp < time.Now().UnixNano()) {
+	if !exists || (itm.exp > 0 && itm.exp < s.clock.Now().UnixNano()) {
 		return false
 	}
 
 	exp := int64(NoExpiration)
 	if ttl > 0 {
-		exp = time.Now().UnixNano() + ttl.Nanoseconds()
+		exp = s.clock.Now().UnixNano() + ttl.Nanoseconds()
 	}
 
-	s.items[key] = item[T]{value: value, exp: exp}
-	return true
+	_, stored := s.store(key, item[V]{value: value, exp: exp, cost: autoCost})
+	return stored
 }
 
-func (s *shard[T]) getSetEX(key string, value T, ttl time.Duration) (T, bool) {
-	s.mux.Lock()
+func (s *shard[K, V]) getSetEX(key K, value V, ttl time.Duration) (V, bool) {
+	s = s.lock(key)
 	defer s.mux.Unlock()
 
 	itm, exists := s.items[key]
 	// if exists and expired return false
-	if !exists || (itm.exp > 0 && itm.exp < time.Now().UnixNano()) {
-		return zeroV[T](), false
+	if !exists || (itm.exp > 0 && itm.exp < s.clock.Now().UnixNano()) {
+		return zeroV[V](), false
 	}
 
 	exp := int64(NoExpiration)
 	if ttl > 0 {
-		exp = time.Now().UnixNano() + ttl.Nanoseconds()
+		exp = s.clock.Now().UnixNano() + ttl.Nanoseconds()
 	}
 
 	oldValue := itm.value
-	s.items[key] = item[T]{value: value, exp: exp}
+	s.store(key, item[V]{value: value, exp: exp, cost: autoCost})
 	return oldValue, true
 }
 
-func (s *shard[T]) cleanExpired(onEvict func(key string, value T)) int {
+// store writes the item under the shard lock and reports whether the key is new to the shard
+// and whether it was stored at all. Bounded shards evict items to make room for the key,
+// unless the admission filter rejects the new key or the item alone exceeds the shard max cost.
+func (s *shard[K, V]) store(key K, itm item[V]) (added bool, stored bool) {
+	prev, exists := s.items[key]
+	itm.cost = s.resolveCost(key, itm.value, itm.cost, prev, exists)
+
+	if s.policy != nil {
+		if exists {
+			s.policy.OnAccess(key)
+		}
+
+		if !s.makeRoom(key, itm.cost, exists) {
+			if exists {
+				// the old value should not outlive the rejected update
+				s.delete(key)
+				s.onEvict(key, prev.value)
+			}
+			return false, false
+		}
+
+		if !exists {
+			s.policy.OnInsert(key)
+		}
+	}
+
+	s.cost += itm.cost - prev.cost
+	s.schedule(key, &itm, prev)
+	s.items[key] = itm
+	s.publish(key, itm)
+	if len(s.failures) > 0 {
+		delete(s.failures, key)
+	}
+	return !exists, true
+}
+
+// fail caches the loader error for the key, the ttl doubles with every consecutive failure up to max ttl
+func (s *shard[K, V]) fail(key K, err error, ttl, maxTtl time.Duration) {
+	s = s.lock(key)
+	defer s.mux.Unlock()
+
+	if s.failures == nil {
+		s.failures = make(map[K]failure)
+	}
+
+	now := s.clock.Now().UnixNano()
+	f := failure{err: err, attempts: 1}
+	if prev, ok := s.failures[key]; ok && now < prev.exp {
+		f.attempts = prev.attempts + 1
+	}
+
+	backoff := ttl
+	for i := 1; i < f.attempts && backoff < maxTtl; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxTtl)
+
+	f.retry = now + backoff.Nanoseconds()
+	f.exp = f.retry + backoff.Nanoseconds()
+	s.failures[key] = f
+	heap.Push(&s.failureExpiries, expiryEntry[K]{key: key, exp: f.exp})
+}
+
+// postponeRefresh moves the refresh time of the loaded item forward, when its refresh has failed
+func (s *shard[K, V]) postponeRefresh(key K, refresh int64) {
+	s = s.lock(key)
+	defer s.mux.Unlock()
+
+	itm, ok := s.items[key]
+	if !ok || itm.refresh == 0 || itm.refresh >= refresh {
+		return
+	}
+
+	itm.refresh = refresh
+	s.items[key] = itm
+	s.publish(key, itm)
+}
+
+// failed returns the cached loader error of the key, until the key can be loaded again
+func (s *shard[K, V]) failed(key K) error {
+	s = s.rlock(key)
+	defer s.mux.RUnlock()
+
+	f, ok := s.failures[key]
+	if !ok || s.clock.Now().UnixNano() >= f.retry {
+		return nil
+	}
+	return f.err
+}
+
+// resolveCost returns the given cost, unless it is autoCost, in which case
+// the cost func is used or the cost of the previous item is kept. Negative costs of the cost func count as 0,
+// so that they can not free room in the shard.
+func (s *shard[K, V]) resolveCost(key K, value V, cost int64, prev item[V], exists bool) int64 {
+	switch {
+	case s.maxCost == 0:
+		return 0
+	case cost != autoCost:
+		return cost
+	case s.costFunc != nil:
+		return max(s.costFunc(key, value), 0)
+	case exists:
+		return prev.cost
+	default:
+		return 1
+	}
+}
+
+// makeRoom evicts items until the candidate key with the given cost fits into the shard,
+// returns false if the candidate should not be stored. The victims are taken out of the policy
+// until the candidate fits and are evicted only once the candidate is admitted, a rejected candidate
+// puts them back, so that it does not cost the shard any items.
+func (s *shard[K, V]) makeRoom(candidate K, cost int64, exists bool) bool {
+	if s.maxCost > 0 && cost > s.maxCost {
+		return false
+	}
+
+	if !exists && s.admission != nil {
+		s.admission.record(candidate)
+	}
+
+	var (
+		victims []K
+		freed   int64
+	)
+	for s.overflows(candidate, cost, exists, len(victims), freed) {
+		key, ok := s.policy.Victim()
+		if !ok || key == candidate {
+			// the candidate itself is the least worth keeping, its update can not make room
+			s.restoreVictims(victims)
+			return false
+		}
+
+		s.policy.OnRemove(key)
+		itm, found := s.items[key]
+		if !found {
+			// the policy is out of sync with the shard, the key is dropped from the policy
+			continue
+		}
+
+		victims = append(victims, key)
+		freed += itm.cost
+	}
+
+	if !exists && s.admission != nil {
+		for _, key := range victims {
+			if !s.admission.admit(candidate, key) {
+				s.restoreVictims(victims)
+				return false
+			}
+		}
+	}
+
+	for _, key := range victims {
+		itm := s.items[key]
+		s.forget(key)
+		s.onEvict(key, itm.value)
+	}
+
+	return true
+}
+
+// restoreVictims puts the victims of a rejected candidate back into the policy in the order they were taken
+func (s *shard[K, V]) restoreVictims(victims []K) {
+	for _, key := range victims {
+		s.policy.OnInsert(key)
+	}
+}
+
+// overflows reports whether the candidate does not fit into the shard,
+// after the given number of victims with the given total cost are evicted
+func (s *shard[K, V]) overflows(candidate K, cost int64, exists bool, victims int, freed int64) bool {
+	if !exists && s.capacity > 0 && len(s.items)-victims >= s.capacity {
+		return true
+	}
+
+	return s.maxCost > 0 && s.cost-freed-s.items[candidate].cost+cost > s.maxCost
+}
+
+func (s *shard[K, V]) delete(key K) {
+	if s.policy != nil {
+		s.policy.OnRemove(key)
+	}
+	s.forget(key)
+}
+
+// forget deletes the item, that was already removed from the policy
+func (s *shard[K, V]) forget(key K) {
+	s.cost -= s.items[key].cost
+	delete(s.items, key)
+	s.unpublish(key)
+}
+
+// schedule makes sure that the expiration of the item is checked in time. An item has at most one
+// valid entry in the expiry heap, which is kept when it is due no later than the new expiration,
+// since it gets rescheduled when popped, that way sliding expiration does not touch the heap on every read.
+func (s *shard[K, V]) schedule(key K, itm *item[V], prev item[V]) {
+	itm.sched = prev.sched
+	if itm.exp <= 0 || (prev.sched > 0 && prev.sched <= itm.exp) {
+		return
+	}
+
+	itm.sched = itm.exp
+	heap.Push(&s.expiries, expiryEntry[K]{key: key, exp: itm.exp})
+}
+
+// cleanExpired deletes the items that are due, it only touches the expiry heap entries,
+// that are due, so the time spent under the lock is proportional to the number of expirations.
+// At most limit entries are checked, it returns the number of checked entries, the number of deleted items
+// and whether there are more due entries left.
+func (s *shard[K, V]) cleanExpired(limit int) (checked int, deleted int, more bool) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
-	deleted := 0
-	now := time.Now().UnixNano()
-	for k, itm := range s.items {
-		if itm.exp > 0 && itm.exp < now {
-			delete(s.items, k)
-			onEvict(k, itm.value)
+	now := s.clock.Now().UnixNano()
+	for len(s.expiries) > 0 && s.expiries[0].exp < now {
+		if checked >= limit {
+			more = true
+			break
+		}
+
+		checked++
+		e := heap.Pop(&s.expiries).(expiryEntry[K])
+		itm, found := s.items[e.key]
+		if !found || itm.sched != e.exp {
+			// the item was deleted or rescheduled to an earlier time
+			continue
+		}
+
+		switch {
+		case itm.exp <= 0:
+			itm.sched = 0
+			s.items[e.key] = itm
+		case itm.exp < now:
+			s.delete(e.key)
+			s.onEvict(e.key, itm.value)
 			deleted++
+		default:
+			// expiration was pushed forward since the item was scheduled
+			itm.sched = itm.exp
+			s.items[e.key] = itm
+			heap.Push(&s.expiries, expiryEntry[K]{key: e.key, exp: itm.exp})
+		}
+	}
+
+	for len(s.failureExpiries) > 0 && s.failureExpiries[0].exp < now {
+		if checked >= limit {
+			more = true
+			break
+		}
+
+		checked++
+		e := heap.Pop(&s.failureExpiries).(expiryEntry[K])
+		if f, ok := s.failures[e.key]; ok && f.exp == e.exp {
+			delete(s.failures, e.key)
+		}
+	}
+
+	s.compactExpiries()
+	return checked, deleted, more
+}
+
+// compactExpiries rebuilds the expiry heap, when it is mostly made of entries
+// of deleted or rescheduled items, which otherwise would be discarded only when due
+func (s *shard[K, V]) compactExpiries() {
+	if len(s.expiries) <= 2*len(s.items)+expiryCompactionSlack {
+		return
+	}
+
+	expiries := make(expiryHeap[K], 0, len(s.items))
+	for k, itm := range s.items {
+		if itm.sched > 0 {
+			expiries = append(expiries, expiryEntry[K]{key: k, exp: itm.sched})
 		}
 	}
-	return deleted
+	heap.Init(&expiries)
+	s.expiries = expiries
 }
 
-func (s *shard[T]) remove(key string) (T, bool) {
+// evictShare evicts the given share of the items, at least one item is evicted from a non-empty shard.
+// Victims are chosen by the eviction policy of bounded shards, otherwise any items may be evicted.
+func (s *shard[K, V]) evictShare(share float64) int {
 	s.mux.Lock()
 	defer s.mux.Unlock()
+
+	n := int(math.Ceil(float64(len(s.items)) * share))
+	evicted := 0
+	for evicted < n {
+		key, ok := s.anyVictim()
+		if !ok {
+			break
+		}
+
+		itm := s.items[key]
+		s.delete(key)
+		s.onEvict(key, itm.value)
+		evicted++
+	}
+	return evicted
+}
+
+func (s *shard[K, V]) anyVictim() (K, bool) {
+	if s.policy != nil {
+		key, ok := s.policy.Victim()
+		if _, found := s.items[key]; ok && found {
+			return key, true
+		}
+	}
+
+	for key := range s.items {
+		return key, true
+	}
+
+	var zero K
+	return zero, false
+}
+
+func (s *shard[K, V]) remove(key K) (V, bool) {
+	s = s.lock(key)
+	defer s.mux.Unlock()
 	itm, found := s.items[key]
 	if !found {
-		return zeroV[T](), false
+		return zeroV[V](), false
 	}
 
-	now := time.Now().UnixNano()
+	now := s.clock.Now().UnixNano()
 	if itm.exp > 0 && itm.exp > now {
-		return zeroV[T](), false
+		return zeroV[V](), false
 	}
 
-	delete(s.items, key)
+	s.delete(key)
 
 	return itm.value, true
 }
 
-func (s *shard[T]) countPrecise() int {
+// retire makes the shard forward all the operations on keys to the shards of the new layout
+func (s *shard[K, V]) retire(forward func(key K) *shard[K, V]) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.forward = forward
+	// reads fall back to the lock, which forwards them
+	s.view.Store(nil)
+}
+
+// move moves the item of the key from the retired shard to its new shard
+func (s *shard[K, V]) move(key K, to *shard[K, V]) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	itm, ok := s.items[key]
+	if !ok {
+		return
+	}
+
+	delete(s.items, key)
+	// the new shard is locked under the lock of the retired one, so the item is never missing from both
+	to.adopt(key, itm)
+}
+
+// drain moves at most limit items of the retired shard to their new shards
+// and reports whether there are items left
+func (s *shard[K, V]) drain(limit int, route func(key K) *shard[K, V]) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for key, itm := range s.items {
+		if limit == 0 {
+			return true
+		}
+		limit--
+
+		delete(s.items, key)
+		route(key).adopt(key, itm)
+	}
+
+	s.items = make(map[K]item[V])
+	s.expiries = nil
+	s.cost = 0
+	return false
+}
+
+// adopt stores the item moved from a retired shard, the items, that have expired or can not be stored,
+// are evicted, the same as the stale copy, if the key was written to the new shard in the meantime
+func (s *shard[K, V]) adopt(key K, itm item[V]) {
+	s = s.lock(key)
+	defer s.mux.Unlock()
+
+	_, exists := s.items[key]
+	if exists || (itm.exp > 0 && s.clock.Now().UnixNano() > itm.exp) {
+		s.onEvict(key, itm.value)
+		return
+	}
+
+	itm.sched = 0
+	if _, stored := s.store(key, itm); !stored {
+		s.onEvict(key, itm.value)
+	}
+}
+
+func (s *shard[K, V]) totalCost() int64 {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.cost
+}
+
+func (s *shard[K, V]) stats() ShardStats {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return ShardStats{Entries: len(s.items), Cost: s.cost}
+}
+
+func (s *shard[K, V]) countPrecise() int {
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 	return len(s.items)
@@ -0,0 +1,97 @@
+package litecache
+
+import "sync/atomic"
+
+type sieveNode[K comparable] struct {
+	key     K
+	visited atomic.Bool
+	prev    *sieveNode[K]
+	next    *sieveNode[K]
+}
+
+// sievePolicy implements SIEVE eviction, new keys are queued at the head,
+// and the hand moves from the tail towards the head, evicting the first key that was not visited
+// since the hand passed it last time. A hit only flips the visited flag, so it does not need the write lock.
+type sievePolicy[K comparable] struct {
+	nodes map[K]*sieveNode[K]
+	head  *sieveNode[K]
+	tail  *sieveNode[K]
+	hand  *sieveNode[K]
+}
+
+// NewSIEVEPolicy creates a SIEVE policy, reads of the shards using it stay on the read lock
+func NewSIEVEPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	return &sievePolicy[K]{
+		nodes: make(map[K]*sieveNode[K], capacity),
+	}
+}
+
+func (p *sievePolicy[K]) OnAccess(key K) {
+	p.OnReadAccess(key)
+}
+
+func (p *sievePolicy[K]) OnReadAccess(key K) {
+	if n, ok := p.nodes[key]; ok && !n.visited.Load() {
+		n.visited.Store(true)
+	}
+}
+
+func (p *sievePolicy[K]) OnInsert(key K) {
+	n := &sieveNode[K]{key: key, next: p.head}
+	if p.head != nil {
+		p.head.prev = n
+	}
+	p.head = n
+	if p.tail == nil {
+		p.tail = n
+	}
+	p.nodes[key] = n
+}
+
+func (p *sievePolicy[K]) OnRemove(key K) {
+	n, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+
+	if p.hand == n {
+		p.hand = n.prev
+	}
+
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		p.head = n.next
+	}
+
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		p.tail = n.prev
+	}
+
+	delete(p.nodes, key)
+}
+
+func (p *sievePolicy[K]) Victim() (K, bool) {
+	if p.tail == nil {
+		var zero K
+		return zero, false
+	}
+
+	n := p.hand
+	if n == nil {
+		n = p.tail
+	}
+
+	for n.visited.Load() {
+		n.visited.Store(false)
+		n = n.prev
+		if n == nil {
+			n = p.tail
+		}
+	}
+
+	p.hand = n
+	return n.key, true
+}
@@ -0,0 +1,79 @@
+package litecache
+
+import (
+	"context"
+	"sync"
+)
+
+type flight[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// flightGroup deduplicates concurrent loads of the same key,
+// the zero value is ready to use
+type flightGroup[K comparable, V any] struct {
+	mux     sync.Mutex
+	flights map[K]*flight[V]
+}
+
+// do calls fn only once for concurrent calls with the same key, the callers that join an ongoing call
+// wait for its result, unless their context is done first. If fn panics, the waiters get ErrLoaderPanicked.
+func (g *flightGroup[K, V]) do(ctx context.Context, key K, fn func() (V, error)) (V, error) {
+	g.mux.Lock()
+	if g.flights == nil {
+		g.flights = make(map[K]*flight[V])
+	}
+
+	if f, ok := g.flights[key]; ok {
+		g.mux.Unlock()
+		select {
+		case <-f.done:
+			return f.value, f.err
+		case <-ctx.Done():
+			return zeroV[V](), ctx.Err()
+		}
+	}
+
+	f := &flight[V]{done: make(chan struct{}), err: ErrLoaderPanicked}
+	g.flights[key] = f
+	g.mux.Unlock()
+
+	defer g.land(key, f)
+	f.value, f.err = fn()
+	return f.value, f.err
+}
+
+// start calls fn in a new goroutine, unless there is an ongoing call with the same key.
+// There is nobody to return a panic of fn to, so it is recovered and the waiters get ErrLoaderPanicked.
+func (g *flightGroup[K, V]) start(key K, fn func() (V, error)) bool {
+	g.mux.Lock()
+	if g.flights == nil {
+		g.flights = make(map[K]*flight[V])
+	}
+
+	if _, ok := g.flights[key]; ok {
+		g.mux.Unlock()
+		return false
+	}
+
+	f := &flight[V]{done: make(chan struct{}), err: ErrLoaderPanicked}
+	g.flights[key] = f
+	g.mux.Unlock()
+
+	go func() {
+		defer g.land(key, f)
+		defer func() { _ = recover() }()
+		f.value, f.err = fn()
+	}()
+
+	return true
+}
+
+func (g *flightGroup[K, V]) land(key K, f *flight[V]) {
+	g.mux.Lock()
+	delete(g.flights, key)
+	g.mux.Unlock()
+	close(f.done)
+}
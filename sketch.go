@@ -0,0 +1,109 @@
+package litecache
+
+import "math/bits"
+
+const (
+	sketchDepth = 4
+	// sketchMaxCount is the saturation point of a counter, 4 bits are enough to tell hot keys apart
+	sketchMaxCount = 15
+)
+
+// countMinSketch estimates key frequencies in constant memory,
+// estimations can only be higher than the real frequencies, never lower
+type countMinSketch struct {
+	rows [sketchDepth][]uint8
+	mask uint64
+}
+
+func newCountMinSketch(width int) *countMinSketch {
+	width = nextPowerOfTwo(width)
+	s := &countMinSketch{mask: uint64(width - 1)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *countMinSketch) increment(h uint64) {
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.rows[i][idx] < sketchMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+}
+
+func (s *countMinSketch) estimate(h uint64) uint8 {
+	count := uint8(sketchMaxCount)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < count {
+			count = c
+		}
+	}
+	return count
+}
+
+// halve ages the sketch, so that keys which used to be popular lose their advantage
+func (s *countMinSketch) halve() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+}
+
+// index picks a counter of the row using double hashing
+func (s *countMinSketch) index(h uint64, row int) uint64 {
+	h1, h2 := h&0xffffffff, h>>32
+	return (h1 + uint64(row)*h2) & s.mask
+}
+
+// doorkeeper is a bloom filter that keeps one-hit wonders out of the sketch
+type doorkeeper struct {
+	bits []uint64
+	mask uint64
+}
+
+func newDoorkeeper(size int) *doorkeeper {
+	size = nextPowerOfTwo(size)
+	if size < 64 {
+		size = 64
+	}
+	return &doorkeeper{
+		bits: make([]uint64, size/64),
+		mask: uint64(size - 1),
+	}
+}
+
+// allow adds the hash to the filter and reports whether it was already there
+func (d *doorkeeper) allow(h uint64) bool {
+	present := true
+	for _, bit := range [2]uint64{h & d.mask, bits.RotateLeft64(h, 32) & d.mask} {
+		word, flag := bit/64, uint64(1)<<(bit%64)
+		if d.bits[word]&flag == 0 {
+			present = false
+			d.bits[word] |= flag
+		}
+	}
+	return present
+}
+
+func (d *doorkeeper) contains(h uint64) bool {
+	for _, bit := range [2]uint64{h & d.mask, bits.RotateLeft64(h, 32) & d.mask} {
+		if d.bits[bit/64]&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *doorkeeper) reset() {
+	clear(d.bits)
+}
+
+func nextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
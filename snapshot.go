@@ -0,0 +1,243 @@
+package litecache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// snapshotMagic opens every snapshot, so that other files are not mistaken for one
+	snapshotMagic = "LCSNAP"
+	// snapshotVersion is bumped whenever the layout of the entries changes
+	snapshotVersion byte = 1
+
+	snapshotEntry byte = 1
+	snapshotEnd   byte = 0
+)
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+// snapshotItem is an item copied out of a shard, so that it is encoded without holding the shard lock
+type snapshotItem[K comparable, V any] struct {
+	key K
+	itm item[V]
+}
+
+// SaveSnapshot writes all the live entries with their absolute expiration times to w,
+// the keys and the values are encoded by the codecs from the config. Every shard is copied under its read lock
+// and encoded afterwards, so the snapshot is consistent per shard, but not across the shards.
+// Neither the costs nor the cached loader failures are saved.
+//
+// The snapshot starts with a magic string and a version byte, followed by the entries, each of them
+// is a 1 byte marker, the length prefixed key and value, the expiration, the sliding idle time
+// and the refresh time of the loaded values as varints, a 0 byte marks the end.
+func (c *KeyedCache[K, V]) SaveSnapshot(w io.Writer) error {
+	if err := c.snapshotCodecs(); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(snapshotMagic); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(snapshotVersion); err != nil {
+		return err
+	}
+
+	var buf []byte
+	for _, s := range c.allShards() {
+		for _, e := range s.snapshot() {
+			key, err := c.cfg.keyCodec.Marshal(e.key)
+			if err != nil {
+				return fmt.Errorf("could not encode key %v: %w", e.key, err)
+			}
+
+			value, err := c.cfg.codec.Marshal(e.itm.value)
+			if err != nil {
+				return fmt.Errorf("could not encode value of key %v: %w", e.key, err)
+			}
+
+			buf = append(buf[:0], snapshotEntry)
+			buf = binary.AppendUvarint(buf, uint64(len(key)))
+			buf = append(buf, key...)
+			buf = binary.AppendUvarint(buf, uint64(len(value)))
+			buf = append(buf, value...)
+			buf = binary.AppendVarint(buf, e.itm.exp)
+			buf = binary.AppendVarint(buf, e.itm.idle)
+			buf = binary.AppendVarint(buf, e.itm.refresh)
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := bw.WriteByte(snapshotEnd); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// LoadSnapshot reads the entries written by SaveSnapshot and stores them with their original expiration times,
+// the entries, that have expired since the snapshot was taken, are skipped. Keys, that are already in the cache,
+// keep their current values. Bounded caches evict entries as usual, when the snapshot does not fit.
+// Entries read before an error remain in the cache.
+func (c *KeyedCache[K, V]) LoadSnapshot(r io.Reader) error {
+	if err := c.snapshotCodecs(); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("%w: could not read header: %v", ErrInvalidSnapshot, err)
+	}
+
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("%w: unknown format", ErrInvalidSnapshot)
+	}
+
+	if v := header[len(snapshotMagic)]; v != snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, v)
+	}
+
+	for {
+		marker, err := br.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnapshot, unexpectedEOF(err))
+		}
+
+		switch marker {
+		case snapshotEnd:
+			return nil
+		case snapshotEntry:
+		default:
+			return fmt.Errorf("%w: unknown entry marker %d", ErrInvalidSnapshot, marker)
+		}
+
+		key, itm, err := c.readSnapshotEntry(br)
+		if err != nil {
+			return err
+		}
+
+		if itm.exp > 0 && c.clock.Now().UnixNano() > itm.exp {
+			continue
+		}
+
+		if c.getShard(key).restore(key, itm) {
+			c.len.Add(1)
+		}
+	}
+}
+
+func (c *KeyedCache[K, V]) readSnapshotEntry(br *bufio.Reader) (K, item[V], error) {
+	var (
+		key K
+		itm item[V]
+	)
+
+	keyBytes, err := readSnapshotBytes(br)
+	if err != nil {
+		return key, itm, err
+	}
+
+	valueBytes, err := readSnapshotBytes(br)
+	if err != nil {
+		return key, itm, err
+	}
+
+	for _, field := range []*int64{&itm.exp, &itm.idle, &itm.refresh} {
+		if *field, err = binary.ReadVarint(br); err != nil {
+			return key, itm, fmt.Errorf("%w: %v", ErrInvalidSnapshot, unexpectedEOF(err))
+		}
+	}
+
+	if key, err = c.cfg.keyCodec.Unmarshal(keyBytes); err != nil {
+		return key, itm, fmt.Errorf("%w: could not decode key: %v", ErrInvalidSnapshot, err)
+	}
+
+	if itm.value, err = c.cfg.codec.Unmarshal(valueBytes); err != nil {
+		return key, itm, fmt.Errorf("%w: could not decode value of key %v: %v", ErrInvalidSnapshot, key, err)
+	}
+
+	itm.cost = autoCost
+	return key, itm, nil
+}
+
+// readSnapshotBytes reads a length prefixed field
+func readSnapshotBytes(br *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, unexpectedEOF(err))
+	}
+
+	if n > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: field of %d bytes", ErrInvalidSnapshot, n)
+	}
+
+	// the buffer grows with the data actually read, so a corrupted length can not allocate more than the snapshot holds
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, br, int64(n)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, unexpectedEOF(err))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// unexpectedEOF reports a snapshot, that ends before its end marker, as truncated
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (c *KeyedCache[K, V]) snapshotCodecs() error {
+	if c.cfg.keyCodec == nil {
+		return fmt.Errorf("%w: key codec is required for key type %T", ErrInvalidConfig, *new(K))
+	}
+
+	if c.cfg.codec == nil {
+		return fmt.Errorf("%w: codec is required", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// snapshot copies the items, that have not expired
+func (s *shard[K, V]) snapshot() []snapshotItem[K, V] {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	now := s.clock.Now().UnixNano()
+	items := make([]snapshotItem[K, V], 0, len(s.items))
+	for k, itm := range s.items {
+		if itm.exp > 0 && now > itm.exp {
+			continue
+		}
+		items = append(items, snapshotItem[K, V]{key: k, itm: itm})
+	}
+
+	return items
+}
+
+// restore stores the item read from a snapshot, unless the key is already in the shard and has not expired,
+// it reports whether the key is new to the shard
+func (s *shard[K, V]) restore(key K, itm item[V]) bool {
+	s = s.lock(key)
+	defer s.mux.Unlock()
+
+	if prev, exists := s.items[key]; exists && (prev.exp <= 0 || prev.exp > s.clock.Now().UnixNano()) {
+		return false
+	}
+
+	added, _ := s.store(key, itm)
+	return added
+}
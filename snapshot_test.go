@@ -0,0 +1,243 @@
+package litecache_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denismitr/litecache"
+)
+
+func TestCache_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	t.Run("restores live entries with their expiration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		cfg := litecache.NewDefaultConfig[profile]().WithClock(clock).WithShards(4)
+
+		src, err := litecache.NewWithConfig[profile](ctx, cfg)
+		require.NoError(t, err)
+
+		joe := profile{Name: "Joe", Age: 42, Tags: []string{"a"}}
+		ann := profile{Name: "Ann", Age: 31}
+		src.Set("joe", joe)
+		src.SetTtl("ann", ann, 10*time.Second)
+		src.SetTtl("bob", profile{Name: "Bob"}, time.Second)
+		clock.Advance(2 * time.Second)
+
+		var buf bytes.Buffer
+		require.NoError(t, src.SaveSnapshot(&buf))
+
+		dst, err := litecache.NewWithConfig[profile](ctx, cfg.WithShards(8))
+		require.NoError(t, err)
+		require.NoError(t, dst.LoadSnapshot(&buf))
+
+		assert.Equal(t, 2, dst.Count())
+
+		v, found := dst.Get("joe")
+		assert.True(t, found)
+		assert.Equal(t, joe, v)
+
+		v, found = dst.Get("ann")
+		assert.True(t, found)
+		assert.Equal(t, ann, v)
+
+		_, found = dst.Get("bob")
+		assert.False(t, found)
+
+		// the expiration is absolute, so the time spent after the snapshot counts
+		clock.Advance(9 * time.Second)
+		_, found = dst.Get("ann")
+		assert.False(t, found)
+		_, found = dst.Get("joe")
+		assert.True(t, found)
+	})
+
+	t.Run("skips entries expired since the snapshot", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		cfg := litecache.NewDefaultConfig[int]().WithClock(clock).WithCodec(litecache.JSONCodec[int]{})
+
+		src, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+
+		src.SetTtl("foo", 1, time.Second)
+		src.Set("bar", 2)
+
+		var buf bytes.Buffer
+		require.NoError(t, src.SaveSnapshot(&buf))
+		clock.Advance(2 * time.Second)
+
+		dst, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+		require.NoError(t, dst.LoadSnapshot(&buf))
+
+		assert.Equal(t, 1, dst.Count())
+		assert.Equal(t, 1, dst.CountPrecise())
+		_, found := dst.Get("foo")
+		assert.False(t, found)
+	})
+
+	t.Run("keeps sliding expiration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clock := litecache.NewFakeClock(time.Now())
+		cfg := litecache.NewDefaultConfig[int]().WithClock(clock)
+
+		src, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+		src.SetSliding("foo", 1, 5*time.Second)
+
+		var buf bytes.Buffer
+		require.NoError(t, src.SaveSnapshot(&buf))
+
+		dst, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+		require.NoError(t, dst.LoadSnapshot(&buf))
+
+		for i := 0; i < 3; i++ {
+			clock.Advance(3 * time.Second)
+			_, found := dst.Get("foo")
+			assert.True(t, found)
+		}
+	})
+
+	t.Run("does not overwrite existing keys", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		src := litecache.New[int](ctx)
+		src.Set("foo", 1)
+		src.Set("bar", 2)
+
+		var buf bytes.Buffer
+		require.NoError(t, src.SaveSnapshot(&buf))
+
+		dst := litecache.New[int](ctx)
+		dst.Set("foo", 10)
+		require.NoError(t, dst.LoadSnapshot(&buf))
+
+		assert.Equal(t, 2, dst.Count())
+		v, _ := dst.Get("foo")
+		assert.Equal(t, 10, v)
+		v, _ = dst.Get("bar")
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("bounded cache evicts entries that do not fit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		src := litecache.New[int](ctx)
+		for i := 0; i < 100; i++ {
+			src.Set(fmt.Sprintf("key-%d", i), i)
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, src.SaveSnapshot(&buf))
+
+		cfg := litecache.NewDefaultConfig[int]().WithShards(1).WithMaxEntries(10)
+		dst, err := litecache.NewWithConfig[int](ctx, cfg)
+		require.NoError(t, err)
+		require.NoError(t, dst.LoadSnapshot(&buf))
+
+		assert.Equal(t, 10, dst.Count())
+		assert.Equal(t, 10, dst.CountPrecise())
+	})
+
+	t.Run("integer keys", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		type userID int32
+
+		cfg := litecache.NewKeyedConfig[userID, string]()
+		src, err := litecache.NewKeyed[userID, string](ctx, cfg)
+		require.NoError(t, err)
+		src.Set(-7, "foo")
+		src.Set(1<<30, "bar")
+
+		var buf bytes.Buffer
+		require.NoError(t, src.SaveSnapshot(&buf))
+
+		dst, err := litecache.NewKeyed[userID, string](ctx, cfg)
+		require.NoError(t, err)
+		require.NoError(t, dst.LoadSnapshot(&buf))
+
+		v, found := dst.Get(-7)
+		assert.True(t, found)
+		assert.Equal(t, "foo", v)
+
+		v, found = dst.Get(1 << 30)
+		assert.True(t, found)
+		assert.Equal(t, "bar", v)
+	})
+
+	t.Run("struct keys require a key codec", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		type point struct{ X, Y int }
+
+		cfg := litecache.NewKeyedConfig[point, int]().WithKeyHash(func(p point) uint64 {
+			return uint64(p.X*31 + p.Y)
+		})
+		c, err := litecache.NewKeyed[point, int](ctx, cfg)
+		require.NoError(t, err)
+		c.Set(point{X: 1, Y: 2}, 3)
+
+		var buf bytes.Buffer
+		require.ErrorIs(t, c.SaveSnapshot(&buf), litecache.ErrInvalidConfig)
+
+		cfg = cfg.WithKeyCodec(litecache.JSONCodec[point]{})
+		c, err = litecache.NewKeyed[point, int](ctx, cfg)
+		require.NoError(t, err)
+		c.Set(point{X: 1, Y: 2}, 3)
+		require.NoError(t, c.SaveSnapshot(&buf))
+
+		dst, err := litecache.NewKeyed[point, int](ctx, cfg)
+		require.NoError(t, err)
+		require.NoError(t, dst.LoadSnapshot(&buf))
+
+		v, found := dst.Get(point{X: 1, Y: 2})
+		assert.True(t, found)
+		assert.Equal(t, 3, v)
+	})
+
+	t.Run("invalid snapshots", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		src := litecache.New[int](ctx)
+		src.Set("foo", 1)
+
+		var buf bytes.Buffer
+		require.NoError(t, src.SaveSnapshot(&buf))
+		valid := buf.Bytes()
+
+		unsupported := append([]byte{}, valid...)
+		unsupported[len("LCSNAP")] = 99
+
+		for name, data := range map[string][]byte{
+			"empty":               nil,
+			"not a snapshot":      []byte("definitely not a snapshot"),
+			"unsupported version": unsupported,
+			"truncated":           valid[:len(valid)-1],
+			"huge field length":   []byte("LCSNAP\x01\x01\xff\xff\xff\xff\x07"),
+		} {
+			dst := litecache.New[int](ctx)
+			assert.ErrorIs(t, dst.LoadSnapshot(bytes.NewReader(data)), litecache.ErrInvalidSnapshot, name)
+		}
+	})
+}
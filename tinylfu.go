@@ -0,0 +1,65 @@
+package litecache
+
+import "sync"
+
+// tinyLFUSampleFactor defines after how many recorded accesses, relative to the shard capacity,
+// the frequencies are aged
+const tinyLFUSampleFactor = 10
+
+// tinyLFU is an admission filter, that lets a new key into a full shard
+// only if it is estimated to be accessed more often than the eviction candidate.
+// It has its own lock, since reads of shards with ReadAccessPolicy are recorded under the shard read lock.
+type tinyLFU[K comparable] struct {
+	mux        sync.Mutex
+	hasher     Hasher[K]
+	sketch     *countMinSketch
+	doorkeeper *doorkeeper
+	samples    int
+	sampleSize int
+}
+
+func newTinyLFU[K comparable](capacity int, h Hasher[K]) *tinyLFU[K] {
+	return &tinyLFU[K]{
+		hasher:     h,
+		sketch:     newCountMinSketch(capacity * 2),
+		doorkeeper: newDoorkeeper(capacity * 4),
+		sampleSize: capacity * tinyLFUSampleFactor,
+	}
+}
+
+func (f *tinyLFU[K]) record(key K) {
+	h := f.hash(key)
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.doorkeeper.allow(h) {
+		f.sketch.increment(h)
+	}
+
+	f.samples++
+	if f.samples >= f.sampleSize {
+		f.sketch.halve()
+		f.doorkeeper.reset()
+		f.samples = 0
+	}
+}
+
+func (f *tinyLFU[K]) estimate(key K) int {
+	h := f.hash(key)
+	freq := int(f.sketch.estimate(h))
+	if f.doorkeeper.contains(h) {
+		freq++
+	}
+	return freq
+}
+
+// admit reports whether the candidate is worth keeping instead of the victim
+func (f *tinyLFU[K]) admit(candidate, victim K) bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.estimate(candidate) > f.estimate(victim)
+}
+
+// hash spreads the key hash, because keys of the same shard share the bits used for shard selection
+func (f *tinyLFU[K]) hash(key K) uint64 {
+	return mix64(f.hasher.Hash(key))
+}
@@ -0,0 +1,102 @@
+package litecache
+
+import (
+	"context"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+const (
+	DefaultMemoryCheckInterval = 1 * time.Second
+
+	// memoryLimitRatio defines at which share of the runtime memory limit the watchdog starts evicting,
+	// so that the cache shrinks before the runtime has to fight for memory
+	memoryLimitRatio = 0.9
+	// memoryEvictionRatio is the share of entries evicted from every shard under memory pressure
+	memoryEvictionRatio = 0.1
+
+	heapLiveMetric = "/gc/heap/live:bytes"
+	gcCyclesMetric = "/gc/cycles/total:gc-cycles"
+)
+
+// watchdog evicts entries from all the shards, when the live heap exceeds the soft limit
+type watchdog[K comparable, V any] struct {
+	ctx       context.Context
+	clock     Clock
+	interval  time.Duration
+	softLimit int64
+	shards    func() []*shard[K, V]
+	samples   []metrics.Sample
+	// evictedAt is the gc cycle of the last eviction, live heap reflects evictions only after the next cycle
+	evictedAt uint64
+}
+
+// newWatchdog creates a watchdog, soft limit of 0 means the runtime memory limit is used
+func newWatchdog[K comparable, V any](
+	ctx context.Context,
+	clock Clock,
+	runEvery time.Duration,
+	softLimit int64,
+	shards func() []*shard[K, V],
+) *watchdog[K, V] {
+	return &watchdog[K, V]{
+		ctx:       ctx,
+		clock:     clock,
+		interval:  runEvery,
+		softLimit: softLimit,
+		shards:    shards,
+		samples: []metrics.Sample{
+			{Name: heapLiveMetric},
+			{Name: gcCyclesMetric},
+		},
+	}
+}
+
+func (w *watchdog[K, V]) run() {
+	// the ticker is created before the goroutine starts, so that it counts from the creation of the cache
+	tick := w.clock.NewTicker(w.interval)
+	go func() {
+		for {
+			select {
+			case <-w.ctx.Done():
+				tick.Stop()
+				return
+			case <-tick.C():
+				w.check()
+			}
+		}
+	}()
+}
+
+func (w *watchdog[K, V]) check() {
+	limit := w.limit()
+	if limit == math.MaxInt64 {
+		return
+	}
+
+	metrics.Read(w.samples)
+	heapLive, cycles := w.samples[0].Value.Uint64(), w.samples[1].Value.Uint64()
+	if heapLive <= uint64(limit) || (w.evictedAt > 0 && cycles <= w.evictedAt) {
+		return
+	}
+
+	for _, s := range w.shards() {
+		s.evictShare(memoryEvictionRatio)
+	}
+	w.evictedAt = cycles
+}
+
+func (w *watchdog[K, V]) limit() int64 {
+	if w.softLimit > 0 {
+		return w.softLimit
+	}
+
+	// a negative input does not change the limit, it only returns the current one
+	limit := debug.SetMemoryLimit(-1)
+	if limit == math.MaxInt64 {
+		return limit
+	}
+	return int64(float64(limit) * memoryLimitRatio)
+}